@@ -0,0 +1,93 @@
+package goconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/h2oai/goconfig/structtag"
+)
+
+// RenameAliases is used by the file formats to support the old names listed
+// on the cfgAlias tag. It moves the values found under an alias in the
+// decoded document m to the key of its field, as returned by keyOf, and
+// reports every deprecated key through Warn. It returns true when m changed.
+func RenameAliases(config interface{}, m interface{}, keyOf func(field reflect.StructField) string) (renamed bool) {
+	renamed = renameAliases(reflect.TypeOf(config), reflect.ValueOf(m), keyOf, "")
+	return
+}
+
+func renameAliases(t reflect.Type, m reflect.Value, keyOf func(field reflect.StructField) string, path string) (renamed bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for m.Kind() == reflect.Interface || m.Kind() == reflect.Ptr {
+		m = m.Elem()
+	}
+
+	switch m.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < m.Len(); i++ {
+			renamed = renameAliases(t, m.Index(i), keyOf, path) || renamed
+		}
+		return
+	case reflect.Map:
+	default:
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get(Tag) == "-" {
+			continue
+		}
+		key := keyOf(field)
+		if key == "" || key == "-" {
+			continue
+		}
+
+		k, ok := lookupKey(m, key)
+		if ok && structtag.IsDeprecated(&field) {
+			Warn(structtag.Deprecation(&field, fmt.Sprintf("config file key %q", path+key), ""))
+		}
+		if !ok {
+			for _, alias := range strings.Split(field.Tag.Get(structtag.TagAlias), ",") {
+				alias = strings.TrimSpace(alias)
+				if alias == "" {
+					continue
+				}
+				var ak reflect.Value
+				ak, ok = lookupKey(m, alias)
+				if !ok {
+					continue
+				}
+				k = reflect.ValueOf(key).Convert(m.Type().Key())
+				m.SetMapIndex(k, m.MapIndex(ak))
+				m.SetMapIndex(ak, reflect.Value{})
+				Warn(structtag.Deprecation(&field,
+					fmt.Sprintf("config file key %q", path+alias),
+					fmt.Sprintf("%q", path+key)))
+				renamed = true
+				break
+			}
+		}
+		if ok {
+			renamed = renameAliases(field.Type, m.MapIndex(k), keyOf, path+key+".") || renamed
+		}
+	}
+	return
+}
+
+// lookupKey returns the key of m that matches name ignoring case
+func lookupKey(m reflect.Value, name string) (key reflect.Value, ok bool) {
+	for _, k := range m.MapKeys() {
+		if strings.EqualFold(fmt.Sprint(k.Interface()), name) {
+			key, ok = k, true
+			return
+		}
+	}
+	return
+}
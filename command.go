@@ -0,0 +1,179 @@
+package goconfig
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/h2oai/goconfig/goenv"
+	"github.com/h2oai/goconfig/goflags"
+	"github.com/h2oai/goconfig/structtag"
+	"github.com/h2oai/goconfig/validate"
+)
+
+type commandMeta struct {
+	Name  string
+	Help  string
+	Field reflect.StructField
+	Value reflect.Value
+}
+
+var (
+	// ErrUnknownCommand is returned when the command line names a subcommand that does not exist
+	ErrUnknownCommand = errors.New("unknown command")
+
+	// commands available at the level being parsed, listed by PrintCommands
+	commands []commandMeta
+
+	// commandPath is the list of subcommands parsed so far
+	commandPath []string
+)
+
+// ParseCommand parses the configuration like Parse, sub-structures tagged
+// with cfgCommand are subcommands and are only parsed when their name
+// follows the global flags, like "app -debug serve -port 8080". Each
+// subcommand reads its environment variables with the field name added to
+// PrefixEnv, like $APP_SERVE_PORT, and has its own flags and help.
+// The names of the selected subcommands are returned, nested subcommands
+// are supported.
+func ParseCommand(config interface{}) (command []string, err error) {
+	commandPath = nil
+	commands, err = listCommands(config)
+	if err != nil {
+		return
+	}
+
+	err = Parse(config)
+	if err != nil {
+		return
+	}
+
+	args := flag.Args()
+	if DisableFlags {
+		args = os.Args[1:]
+	}
+	command, err = parseCommand(args, PrefixEnv, []interface{}{config})
+	return
+}
+
+func listCommands(config interface{}) (list []commandMeta, err error) {
+	st := reflect.TypeOf(config)
+	if st.Kind() != reflect.Ptr {
+		err = structtag.ErrNotAPointer
+		return
+	}
+	if st.Elem().Kind() != reflect.Struct {
+		err = structtag.ErrNotAStruct
+		return
+	}
+
+	refValue := reflect.ValueOf(config).Elem()
+	for i := 0; i < refValue.NumField(); i++ {
+		field := refValue.Type().Field(i)
+		if !structtag.IsCommand(&field) || field.PkgPath != "" {
+			continue
+		}
+		if field.Type.Kind() != reflect.Struct {
+			err = fmt.Errorf("command %v: %w", field.Tag.Get(structtag.TagCommand), structtag.ErrNotAStruct)
+			return
+		}
+		list = append(list, commandMeta{
+			Name:  field.Tag.Get(structtag.TagCommand),
+			Help:  field.Tag.Get(TagHelper),
+			Field: field,
+			Value: refValue.Field(i),
+		})
+	}
+	return
+}
+
+// prefixEnv returns the prefix of the environment variables of the command
+func (c commandMeta) prefixEnv(prefix string) string {
+	name := c.Field.Tag.Get(Tag)
+	if name == "" {
+		name = c.Field.Name
+	}
+	if prefix != "" {
+		name = prefix + structtag.TagSeparator + name
+	}
+	return name
+}
+
+// parseCommand parses the subcommand named by args[0], the flags of the
+// parents are accepted after its name
+func parseCommand(args []string, prefixEnv string, parents []interface{}) (command []string, err error) {
+	if len(commands) == 0 || len(args) == 0 {
+		return
+	}
+
+	var cmd *commandMeta
+	for i := range commands {
+		if commands[i].Name == args[0] {
+			cmd = &commands[i]
+			break
+		}
+	}
+	if cmd == nil {
+		err = fmt.Errorf("%w %q", ErrUnknownCommand, args[0])
+		return
+	}
+	commandPath = append(commandPath, cmd.Name)
+	command = append(command, cmd.Name)
+
+	sub := cmd.Value.Addr().Interface()
+	commands, err = listCommands(sub)
+	if err != nil {
+		return
+	}
+
+	prefixEnv = cmd.prefixEnv(prefixEnv)
+
+	setupEnv(prefixEnv)
+	goenv.PrintDefaultsOutput = ""
+	err = goenv.Parse(sub)
+	if err != nil {
+		return
+	}
+
+	args = args[1:]
+	if !DisableFlags {
+		flag.CommandLine = flag.NewFlagSet(
+			os.Args[0]+" "+strings.Join(commandPath, " "),
+			flag.CommandLine.ErrorHandling())
+		setupFlags()
+		err = goflags.ParseArgs(sub, args, parents...)
+		if err != nil {
+			return
+		}
+		args = flag.Args()
+	}
+
+	setupValidate()
+	err = validate.Parse(sub)
+	if err != nil {
+		return
+	}
+
+	var subcommand []string
+	subcommand, err = parseCommand(args, prefixEnv, append(parents, sub))
+	command = append(command, subcommand...)
+	return
+}
+
+// PrintCommands print the subcommands available on the command line
+func PrintCommands() {
+	if len(commands) == 0 {
+		return
+	}
+	fmt.Println("Commands:")
+	for _, c := range commands {
+		fmt.Printf("  %v\n", c.Name)
+		if c.Help != "" {
+			fmt.Printf("    \t%v\n", c.Help)
+		}
+	}
+	fmt.Println()
+}
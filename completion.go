@@ -0,0 +1,65 @@
+package goconfig
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/h2oai/goconfig/goflags"
+)
+
+// CompletionFlag is the name of a hidden flag that prints the shell
+// completion script (bash, zsh or fish) and exits, disabled when empty
+var CompletionFlag string
+
+// Completion writes a completion script for shell (bash, zsh or fish)
+// covering the command line flags generated from config
+func Completion(config interface{}, shell string, w io.Writer) (err error) {
+	goflags.Prefix = PrefixFlag
+	goflags.Separator = FlagSeparator
+	goflags.DoubleDash = DoubleDashFlags
+	goflags.Naming = FlagNaming
+	goflags.SetTag(Tag)
+	goflags.SetTagDefault(TagDefault)
+	goflags.SetTagHelper(TagHelper)
+	err = goflags.Completion(config, shell, w)
+	return
+}
+
+// lookupCompletionFlag returns the shell passed to CompletionFlag on the command line
+func lookupCompletionFlag(args []string) (shell string, ok bool) {
+	if CompletionFlag == "" {
+		return
+	}
+	for i, arg := range args {
+		if arg == "--" {
+			return
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name == arg {
+			continue
+		}
+		if name == CompletionFlag && i+1 < len(args) {
+			shell, ok = args[i+1], true
+			return
+		}
+		if strings.HasPrefix(name, CompletionFlag+"=") {
+			shell, ok = strings.TrimPrefix(name, CompletionFlag+"="), true
+			return
+		}
+	}
+	return
+}
+
+func printCompletion(config interface{}) (err error) {
+	shell, ok := lookupCompletionFlag(os.Args[1:])
+	if !ok {
+		return
+	}
+	err = Completion(config, shell, os.Stdout)
+	if err != nil {
+		return
+	}
+	os.Exit(0)
+	return
+}
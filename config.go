@@ -6,8 +6,12 @@ package goconfig
 import (
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path"
+	"reflect"
+	"strings"
 	"time"
 
 	"path/filepath"
@@ -15,6 +19,8 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/h2oai/goconfig/goenv"
 	"github.com/h2oai/goconfig/goflags"
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/naming"
 	"github.com/h2oai/goconfig/structtag"
 	"github.com/h2oai/goconfig/validate"
 )
@@ -24,6 +30,34 @@ type Fileformat struct {
 	Extension   string
 	Load        func(config interface{}) (err error)
 	PrepareHelp func(config interface{}) (help string, err error)
+
+	// Name of the format, like "yaml", defaults to Extension without the dot
+	Name string
+
+	// Extensions are other extensions of the format, like ".yml"
+	Extensions []string
+
+	// MIMETypes of the format, like "application/yaml"
+	MIMETypes []string
+
+	// Comments is set when the documents of the format can have comments
+	Comments bool
+
+	// Priority decides which of two formats using the same name, extension
+	// or MIME type is registered, the built-in formats have priority 0
+	Priority int
+
+	// Decode reads a document from r, used by LoadReader, LoadBytes and LoadFS
+	Decode func(r io.Reader, config interface{}) (err error)
+
+	// Encode writes config as a document to w, used by Save
+	Encode func(w io.Writer, config interface{}) (err error)
+
+	// KeyOf returns the document key of a field, used by Patch
+	KeyOf func(field reflect.StructField) string
+
+	// Patch changes the values of the document doc keeping its layout, used by Patch
+	Patch func(doc []byte, edits []Edit) (ret []byte, err error)
 }
 
 var (
@@ -52,6 +86,18 @@ var (
 	// PrefixFlag is a string that would be placed at the beginning of the generated Flag tags.
 	PrefixFlag string
 
+	// FlagSeparator is placed between the names of nested fields on the command line, like -mongodb_host, -mongodb.host or -mongodb-host
+	FlagSeparator string
+
+	// AtFileValues reads every flag and environment variable value starting with @ from the file named after it, like -tls_ca=@/etc/ca.pem, fields can also enable it with cfgAtFile:"true"
+	AtFileValues bool
+
+	// EnvFiles reads every environment variable from the file named by <VAR>_FILE, like DB_PASSWORD_FILE=/run/secrets/db, fields can also enable it with cfgSecret:"true"
+	EnvFiles bool
+
+	// DoubleDashFlags shows long flags with two dashes in the help, like --mongodb_host
+	DoubleDashFlags bool
+
 	// PrefixEnv is a string that would be placed at the beginning of the generated Event tags.
 	PrefixEnv string
 
@@ -61,7 +107,10 @@ var (
 	//Usage is a function to show the help, can be replaced by your own version.
 	Usage func()
 
-	// Formats is the list of registered formats.
+	// Warn is the function called to report the use of deprecated names, can be replaced by your own logger.
+	Warn func(msg string)
+
+	// Formats is the list of registered formats, use RegisterFormat to add one.
 	Formats []Fileformat
 
 	// FileEnv is the enviroment variable that define the config file
@@ -70,6 +119,9 @@ var (
 	// PathEnv is the enviroment variable that define the config file path
 	PathEnv string
 
+	// FormatEnv is the enviroment variable that define the config file format
+	FormatEnv string
+
 	// WatchConfigFile is the flag to update the config when the config file changes
 	WatchConfigFile bool
 
@@ -77,15 +129,31 @@ var (
 	DisableFlags bool
 
 	// Convert kebabcase (dashes) cmd args to snakecase (underscores) environment variables
+	//
+	// Deprecated: set EnvNaming to naming.ScreamingSnake instead
 	KebabCfgToSnakeEnv bool
+
+	// FlagNaming maps the field names and the cfg tags to the flag names, like naming.Kebab turns MaxConnCount into -max-conn-count, the names are lowercased when nil
+	FlagNaming naming.Mapper
+
+	// EnvNaming maps the field names and the cfg tags to the environment variable names, like naming.ScreamingSnake turns MaxConnCount into $MAX_CONN_COUNT, the names are uppercased when nil
+	EnvNaming naming.Mapper
+
+	// FileNaming maps the field names, and the cfg tags used as keys, to the config file keys, the tags of the file formats are kept as they are
+	FileNaming naming.Mapper
+
+	// CfgFileKeys names the config file keys after the cfg tag in every file format, the tag of the format is only used by the fields without one
+	CfgFileKeys bool
 )
 
 func findFileFormat(extension string) (format Fileformat, err error) {
 	format = Fileformat{}
 	for _, f := range Formats {
-		if f.Extension == extension {
-			format = f
-			return
+		for _, ext := range f.extensions() {
+			if strings.EqualFold(ext, extension) {
+				format = f
+				return
+			}
 		}
 	}
 	err = ErrFileFormatNotDefined
@@ -94,19 +162,31 @@ func findFileFormat(extension string) (format Fileformat, err error) {
 
 func init() {
 	Usage = DefaultUsage
+	Warn = helper.Warn
 	Path = "./"
 	File = ""
 	FileRequired = false
 
+	FlagSeparator = "_"
+
 	FileEnv = "GO_CONFIG_FILE"
 	PathEnv = "GO_CONFIG_PATH"
+	FormatEnv = "GO_CONFIG_FORMAT"
 
 	WatchConfigFile = false
 }
 
 // Parse configuration
 func Parse(config interface{}) (err error) {
+	if !DisableFlags {
+		err = printCompletion(config)
+		if err != nil {
+			return
+		}
+	}
+
 	goenv.Prefix = PrefixEnv
+	goenv.Naming = EnvNaming
 	goenv.Setup(Tag, TagDefault, KebabCfgToSnakeEnv)
 	err = structtag.SetBoolDefaults(config, "")
 	if err != nil {
@@ -115,38 +195,69 @@ func Parse(config interface{}) (err error) {
 
 	lookupEnv()
 
-	ext := path.Ext(File)
-	if ext != "" {
-		if err = loadConfigFromFile(ext, config); err != nil {
+	if hasConfigFile() {
+		if err = loadConfigFromFile(config); err != nil {
 			return
 		}
 	}
 
-	goenv.Prefix = PrefixEnv
-	goenv.Setup(Tag, TagDefault, KebabCfgToSnakeEnv)
+	err = checkEnv(config)
+	if err != nil {
+		return
+	}
+
+	setupEnv(PrefixEnv)
 	err = goenv.Parse(config)
 	if err != nil {
 		return
 	}
 
 	if !DisableFlags {
-		goflags.Prefix = PrefixFlag
-		goflags.Setup(Tag, TagDefault, TagHelper)
-		goflags.Usage = Usage
-		goflags.Preserve = true
+		setupFlags()
 		err = goflags.Parse(config)
 		if err != nil {
 			return
 		}
 	}
 
-	validate.Prefix = PrefixFlag
-	validate.Setup(Tag, TagDefault)
+	setupValidate()
 	err = validate.Parse(config)
 
 	return
 }
 
+// setupEnv prepares goenv to parse the environment variables
+func setupEnv(prefix string) {
+	goenv.Prefix = prefix
+	goenv.Naming = EnvNaming
+	goenv.AtFile = AtFileValues
+	goenv.Files = EnvFiles
+	goenv.Setup(Tag, TagDefault, KebabCfgToSnakeEnv)
+	goenv.Warn = Warn
+}
+
+// setupFlags prepares goflags to parse the command line
+func setupFlags() {
+	goflags.Prefix = PrefixFlag
+	goflags.Separator = FlagSeparator
+	goflags.DoubleDash = DoubleDashFlags
+	goflags.Naming = FlagNaming
+	goflags.AtFile = AtFileValues
+	goflags.Setup(Tag, TagDefault, TagHelper)
+	goflags.Usage = Usage
+	goflags.Warn = Warn
+	goflags.Preserve = true
+}
+
+// setupValidate prepares validate to check the values, naming the fields
+// like the flags
+func setupValidate() {
+	validate.Prefix = PrefixFlag
+	validate.Separator = FlagSeparator
+	validate.Naming = FlagNaming
+	validate.Setup(Tag, TagDefault)
+}
+
 // PrintDefaults print the default help
 func PrintDefaults() {
 	if File != "" {
@@ -157,10 +268,15 @@ func PrintDefaults() {
 
 // DefaultUsage is assigned for Usage function by default
 func DefaultUsage() {
-	fmt.Println("Usage")
+	if len(commandPath) > 0 {
+		fmt.Println("Usage of", strings.Join(commandPath, " "))
+	} else {
+		fmt.Println("Usage")
+	}
 	goflags.PrintDefaults()
 	goenv.PrintDefaults()
 	PrintDefaults()
+	PrintCommands()
 }
 
 func lookupEnv() {
@@ -176,15 +292,30 @@ func lookupEnv() {
 	if val, set := os.LookupEnv(pref + PathEnv); set {
 		Path = val
 	}
+
+	if val, set := os.LookupEnv(pref + FormatEnv); set {
+		Format = val
+	}
 }
 
-func loadConfigFromFile(ext string, config interface{}) (err error) {
+func loadConfigFromFile(config interface{}) (err error) {
 	var format Fileformat
-	format, err = findFileFormat(ext)
+	format, err = configFormat()
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !FileRequired {
+			err = nil
+		}
 		return
 	}
-	err = format.Load(config)
+	loadedFiles = nil
+	err = loadInterpolated(config, func() (err error) {
+		err = loadFormat(format, config, filepath.Join(Path, File))
+		if err != nil {
+			return
+		}
+		err = loadDropIns(config)
+		return
+	})
 	if err != nil {
 		return
 	}
@@ -212,7 +343,7 @@ func asyncParse(config interface{}, w *fsnotify.Watcher, chErr chan<- error, chU
 			}
 
 			if (ev.Op&fsnotify.Write == fsnotify.Write) || (state == 7) {
-				if err := loadConfigFromFile(path.Ext(File), config); err != nil {
+				if err := loadConfigFromFile(config); err != nil {
 					chErr <- err
 					break
 				}
@@ -221,6 +352,9 @@ func asyncParse(config interface{}, w *fsnotify.Watcher, chErr chan<- error, chU
 
 				state = 0
 				w.Add(path.Join(Path, File))
+				if err := watchFiles(w); err != nil {
+					chErr <- err
+				}
 			}
 
 		case err := <-w.Errors:
@@ -238,9 +372,8 @@ func ParseAndWatch(config interface{}) (chChanges chan int64, chErr chan error,
 
 	lookupEnv()
 
-	ext := path.Ext(File)
-	if ext != "" {
-		if err = loadConfigFromFile(ext, config); err != nil {
+	if hasConfigFile() {
+		if err = loadConfigFromFile(config); err != nil {
 			return
 		}
 
@@ -252,12 +385,14 @@ func ParseAndWatch(config interface{}) (chChanges chan int64, chErr chan error,
 			if err = watcher.Add(path.Join(Path, File)); err != nil {
 				return chChanges, chErr, err
 			}
+			if err = watchFiles(watcher); err != nil {
+				return chChanges, chErr, err
+			}
 			go asyncParse(config, watcher, chErr, chChanges)
 		}
 	}
 
-	validate.Prefix = PrefixFlag
-	validate.Setup(Tag, TagDefault)
+	setupValidate()
 	err = validate.Parse(config)
 
 	return
@@ -2,10 +2,19 @@ package goconfig
 
 import (
 	"errors"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/h2oai/goconfig/goflags"
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/naming"
 	"github.com/h2oai/goconfig/structtag"
 )
 
@@ -64,6 +73,8 @@ func ePrepareHelp(config interface{}) (help string, err error) {
 // -=-=-=-=-=-=-=-=-
 
 func TestParse(t *testing.T) {
+	// the flags of the test binary are not defined by testStruct
+	os.Args = []string{"program"}
 
 	s := &testStruct{A: 1, S: testSub{A: 1, B: "2"}}
 	File = "config.txt"
@@ -199,3 +210,1203 @@ func ExampleParse() {
 	println("Name:", cfg.Name, "Value:", cfg.Value)
 
 }
+
+func TestLookupCompletionFlag(t *testing.T) {
+	CompletionFlag = ""
+	_, ok := lookupCompletionFlag([]string{"-completion=bash"})
+	if ok {
+		t.Fatal("completion flag is disabled")
+	}
+
+	CompletionFlag = "completion"
+	defer func() { CompletionFlag = "" }()
+
+	tests := []struct {
+		args  []string
+		shell string
+		ok    bool
+	}{
+		{[]string{"-completion=bash"}, "bash", true},
+		{[]string{"-a=1", "--completion", "zsh"}, "zsh", true},
+		{[]string{"--completion=fish"}, "fish", true},
+		{[]string{"completion", "bash"}, "", false},
+		{[]string{"--", "-completion=bash"}, "", false},
+		{[]string{"-completion"}, "", false},
+	}
+	for _, tt := range tests {
+		shell, ok := lookupCompletionFlag(tt.args)
+		if shell != tt.shell || ok != tt.ok {
+			t.Fatalf("%v: expected %q %v but got %q %v", tt.args, tt.shell, tt.ok, shell, ok)
+		}
+	}
+}
+
+func TestRenameAliases(t *testing.T) {
+	type sub struct {
+		Host string `json:"host" cfgAlias:"hostname"`
+	}
+	type aliases struct {
+		Name  string `json:"name" cfgAlias:"old_name"`
+		Mode  string `json:"mode" cfgDeprecated:"not used anymore"`
+		Mongo []sub  `json:"mongo" cfgAlias:"db"`
+	}
+
+	var warnings []string
+	Warn = func(msg string) {
+		warnings = append(warnings, msg)
+	}
+	defer func() {
+		Warn = helper.Warn
+	}()
+
+	keyOf := func(field reflect.StructField) string {
+		return strings.Split(field.Tag.Get("json"), ",")[0]
+	}
+
+	m := map[string]interface{}{
+		"name":     "new",
+		"old_name": "old",
+		"mode":     "fast",
+		"db":       []interface{}{map[string]interface{}{"hostname": "h"}},
+	}
+	if !RenameAliases(&aliases{}, m, keyOf) {
+		t.Fatal("expected renamed keys")
+	}
+
+	if m["name"] != "new" || m["old_name"] != "old" {
+		t.Fatal("alias must not replace the current key:", m)
+	}
+
+	mongo := m["mongo"].([]interface{})[0].(map[string]interface{})
+	if _, ok := m["db"]; ok || mongo["host"] != "h" {
+		t.Fatal("aliases not renamed:", m)
+	}
+
+	expected := []string{
+		`config file key "mode" is deprecated: not used anymore`,
+		`config file key "db" is deprecated, use "mongo"`,
+		`config file key "mongo.hostname" is deprecated, use "mongo.host"`,
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Fatalf("expected warnings %q but got %q", expected, warnings)
+	}
+
+	if RenameAliases(&aliases{}, map[string]interface{}{"name": "new"}, keyOf) {
+		t.Fatal("no keys expected to be renamed")
+	}
+}
+
+func TestRenameFileKeys(t *testing.T) {
+	type sub struct {
+		Host string `cfg:"host" json:"hostname"`
+	}
+	type fileKeys struct {
+		A     string `cfg:"a" json:"b"`
+		B     string `cfg:"b" json:"a"`
+		Name  string `json:"name"`
+		Mongo []sub  `cfg:"mongodb"`
+	}
+	keyOf := func(field reflect.StructField) string {
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if key == "" {
+			key = field.Name
+		}
+		return key
+	}
+
+	m := map[string]interface{}{"a": "1", "b": "2"}
+	if RenameFileKeys(&fileKeys{}, m, keyOf) || m["a"] != "1" {
+		t.Fatal("keys must not be renamed without CfgFileKeys:", m)
+	}
+
+	CfgFileKeys = true
+	defer func() {
+		CfgFileKeys = false
+	}()
+
+	field, _ := reflect.TypeOf(fileKeys{}).FieldByName("Name")
+	if key := FileKeyOf(keyOf)(field); key != "name" {
+		t.Fatalf("expected the json tag without a cfg tag but got %q", key)
+	}
+
+	m = map[string]interface{}{
+		"a":       "1",
+		"b":       "2",
+		"name":    "n",
+		"mongodb": []interface{}{map[string]interface{}{"host": "h"}},
+	}
+	if !RenameFileKeys(&fileKeys{}, m, keyOf) {
+		t.Fatal("expected renamed keys")
+	}
+	expected := map[string]interface{}{
+		"b":     "1",
+		"a":     "2",
+		"name":  "n",
+		"Mongo": []interface{}{map[string]interface{}{"hostname": "h"}},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected %#v but got %#v", expected, m)
+	}
+}
+
+func TestFileNaming(t *testing.T) {
+	type fileNaming struct {
+		MaxConnCount int
+		LogLevel     string `json:"LOG"`
+		HTTPPort     int    `cfg:"port"`
+	}
+	keyOf := func(field reflect.StructField) string {
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if key == "" {
+			key = field.Name
+		}
+		return key
+	}
+
+	FileNaming = naming.Snake
+	defer func() {
+		FileNaming = nil
+	}()
+
+	m := map[string]interface{}{"max_conn_count": 7, "LOG": "debug", "http_port": 80}
+	if !RenameFileKeys(&fileNaming{}, m, keyOf) {
+		t.Fatal("expected renamed keys")
+	}
+	expected := map[string]interface{}{"MaxConnCount": 7, "LOG": "debug", "HTTPPort": 80}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected %#v but got %#v", expected, m)
+	}
+
+	CfgFileKeys = true
+	defer func() {
+		CfgFileKeys = false
+	}()
+	m = Map(&fileNaming{}, FileKeyOf(keyOf))
+	expected = map[string]interface{}{"max_conn_count": 0, "LOG": "", "port": 0}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected %#v but got %#v", expected, m)
+	}
+}
+
+type serveCommand struct {
+	Port    int  `cfg:"port" cfgDefault:"80"`
+	Verbose bool `cfg:"verbose" cfgDefault:"true"`
+}
+
+type migrateCommand struct {
+	Steps int `cfg:"steps"`
+}
+
+type commandTest struct {
+	Debug   bool           `cfg:"debug"`
+	Serve   serveCommand   `cfg:"serve" cfgCommand:"serve"`
+	Migrate migrateCommand `cfg:"migrate" cfgCommand:"migrate"`
+}
+
+func TestParseCommand(t *testing.T) {
+	File = ""
+	PrefixEnv = "APP"
+	defer func() {
+		PrefixEnv = ""
+	}()
+
+	os.Setenv("APP_SERVE_PORT", "81")
+	os.Setenv("APP_MIGRATE_STEPS", "3")
+	defer func() {
+		os.Unsetenv("APP_SERVE_PORT")
+		os.Unsetenv("APP_MIGRATE_STEPS")
+	}()
+
+	os.Args = []string{"program", "-debug", "serve", "-port", "8080", "extra"}
+
+	s := &commandTest{}
+	goflags.Reset()
+	command, err := ParseCommand(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(command, []string{"serve"}) {
+		t.Fatal("expected serve command but got", command)
+	}
+
+	if !s.Debug {
+		t.Fatal("s.Debug != true")
+	}
+
+	if s.Serve.Port != 8080 || !s.Serve.Verbose {
+		t.Fatalf("unexpected serve command config %+v", s.Serve)
+	}
+
+	if s.Migrate.Steps != 0 {
+		t.Fatal("migrate command must not be parsed, s.Migrate.Steps:", s.Migrate.Steps)
+	}
+
+	os.Args = []string{"program", "serve"}
+	s = &commandTest{}
+	goflags.Reset()
+	_, err = ParseCommand(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Serve.Port != 81 {
+		t.Fatal("s.Serve.Port != 81, s.Serve.Port:", s.Serve.Port)
+	}
+
+	os.Args = []string{"program", "backup"}
+	goflags.Reset()
+	_, err = ParseCommand(&commandTest{})
+	if !errors.Is(err, ErrUnknownCommand) {
+		t.Fatal("ErrUnknownCommand expected but got", err)
+	}
+}
+
+func TestParseCommandGlobalFlags(t *testing.T) {
+	File = ""
+	os.Args = []string{"program", "serve", "-port", "8080", "-debug"}
+
+	s := &commandTest{}
+	goflags.Reset()
+	command, err := ParseCommand(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(command, []string{"serve"}) || !s.Debug || s.Serve.Port != 8080 {
+		t.Fatalf("expected the global flag after the command but got %v %+v", command, s)
+	}
+
+	type dbTest struct {
+		MaxConn int `cfgRequired:"true"`
+	}
+	type requiredTest struct {
+		Debug bool `cfg:"debug"`
+		Run   struct {
+			DB dbTest `cfg:"db"`
+		} `cfg:"run" cfgCommand:"run"`
+	}
+	FlagSeparator = "."
+	FlagNaming = naming.Kebab
+	defer func() {
+		FlagSeparator = "_"
+		FlagNaming = nil
+	}()
+	os.Args = []string{"program", "run"}
+	goflags.Reset()
+	_, err = ParseCommand(&requiredTest{})
+	if err == nil || err.Error() != "-db.max-conn is required" {
+		t.Fatal("expected the error named like the flag but got", err)
+	}
+}
+
+func TestStrictEnv(t *testing.T) {
+	type strictTest struct {
+		Host  string      `cfg:"host" cfgAlias:"hostname"`
+		Port  int         `cfg:"port"`
+		Serve commandTest `cfg:"serve" cfgCommand:"serve"`
+	}
+
+	File = ""
+	PrefixEnv = "APP"
+	StrictEnv = StrictError
+	DisableFlags = true
+	defer func() {
+		PrefixEnv = ""
+		StrictEnv = StrictOff
+		DisableFlags = false
+	}()
+
+	os.Setenv("APP_HOSTNAME", "example.com")
+	os.Setenv("APP_SERVE_DEBUG", "true")
+	os.Setenv("APP_GO_CONFIG_PATH", "./")
+	defer func() {
+		os.Unsetenv("APP_HOSTNAME")
+		os.Unsetenv("APP_SERVE_DEBUG")
+		os.Unsetenv("APP_GO_CONFIG_PATH")
+	}()
+
+	s := &strictTest{}
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("APP_PROT", "8080")
+	defer os.Unsetenv("APP_PROT")
+
+	s = &strictTest{}
+	err = Parse(s)
+	if !errors.Is(err, ErrUnknownEnv) {
+		t.Fatal("expected ErrUnknownEnv but got", err)
+	}
+	if !strings.Contains(err.Error(), "$APP_PROT, did you mean $APP_PORT?") {
+		t.Fatal("unexpected error message:", err)
+	}
+
+	var warnings []string
+	Warn = func(msg string) {
+		warnings = append(warnings, msg)
+	}
+	defer func() {
+		Warn = helper.Warn
+	}()
+	StrictEnv = StrictWarn
+	s = &strictTest{}
+	err = Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) == 0 || !strings.HasPrefix(warnings[0], "unknown environment variable $APP_PROT") {
+		t.Fatal("expected a warning about $APP_PROT but got", warnings)
+	}
+}
+
+type exportSub struct {
+	Host    string `cfg:"host" cfgDefault:"localhost"`
+	Port    int    `cfg:"port" cfgDefault:"27017"`
+	Enabled bool   `cfg:"enabled" cfgDefault:"true"`
+}
+
+type exportTest struct {
+	Name    string        `cfg:"name" cfgDefault:"default"`
+	Count   int           `cfg:"count" cfgDefault:"10"`
+	Big     int64         `cfg:"big"`
+	Ratio   float64       `cfg:"ratio" cfgDefault:"1.5"`
+	Timeout time.Duration `cfg:"timeout" cfgDefault:"5000"`
+	Debug   bool          `cfg:"debug"`
+	Cert    string        `cfg:"cert"`
+	Mongo   exportSub     `cfg:"mongodb"`
+	Source  string        `cfgArg:"0"`
+	Files   []string      `cfgArg:"rest"`
+}
+
+func TestEnvironAndArgs(t *testing.T) {
+	File = ""
+	PrefixEnv = "APP"
+	AtFileValues = true
+	defer func() {
+		PrefixEnv = ""
+		AtFileValues = false
+	}()
+
+	values := []exportTest{
+		{},
+		{
+			Name:    "a name=with spaces",
+			Count:   -3,
+			Big:     1 << 40,
+			Ratio:   0.1,
+			Timeout: 1500 * time.Millisecond,
+			Debug:   true,
+			Cert:    "@not a file",
+			Mongo:   exportSub{Host: "example.com", Port: 0, Enabled: false},
+			Source:  "src",
+			Files:   []string{"a", "-b"},
+		},
+	}
+
+	for _, expected := range values {
+		env, err := Environ(&expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, kv := range env {
+			if !strings.HasPrefix(kv, "APP_") {
+				t.Fatal("unexpected variable", kv)
+			}
+			pair := strings.SplitN(kv, "=", 2)
+			os.Setenv(pair[0], pair[1])
+		}
+
+		DisableFlags = true
+		got := exportTest{Source: expected.Source, Files: expected.Files}
+		err = Parse(&got)
+		DisableFlags = false
+		for _, kv := range env {
+			os.Unsetenv(strings.SplitN(kv, "=", 2)[0])
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Environ round trip, expected %+v but got %+v", expected, got)
+		}
+
+		args, err := Args(&expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Args = append([]string{"program"}, args...)
+		goflags.Reset()
+		got = exportTest{}
+		err = Parse(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(expected.Files) == 0 {
+			got.Files = nil
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Args round trip of %q, expected %+v but got %+v", args, expected, got)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	type mongo struct {
+		Host string `cfg:"host"`
+		Port int    `cfg:"port"`
+		URL  string `cfg:"url"`
+	}
+	type interpolateTest struct {
+		Env     string   `cfg:"env"`
+		Default string   `cfg:"default"`
+		Escaped string   `cfg:"escaped"`
+		Mongo   mongo    `cfg:"mongodb"`
+		Hosts   []string `cfg:"hosts"`
+	}
+
+	os.Setenv("GOCONFIG_TEST_HOST", "db.example.com")
+	defer os.Unsetenv("GOCONFIG_TEST_HOST")
+
+	s := &interpolateTest{
+		Env:     "${GOCONFIG_TEST_HOST}",
+		Default: "${GOCONFIG_TEST_UNSET:-${cfg:mongodb.host}:${cfg:mongodb.port}}",
+		Escaped: "$${GOCONFIG_TEST_HOST}",
+		Mongo: mongo{
+			Host: "${GOCONFIG_TEST_HOST}",
+			Port: 27017,
+			URL:  "mongodb://${cfg:MongoDB.Host}:${cfg:mongodb.port}",
+		},
+		Hosts: []string{"${cfg:mongodb.host}"},
+	}
+	err := interpolate(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &interpolateTest{
+		Env:     "db.example.com",
+		Default: "db.example.com:27017",
+		Escaped: "${GOCONFIG_TEST_HOST}",
+		Mongo: mongo{
+			Host: "db.example.com",
+			Port: 27017,
+			URL:  "mongodb://db.example.com:27017",
+		},
+		Hosts: []string{"db.example.com"},
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expected %+v but got %+v", expected, s)
+	}
+
+	s = &interpolateTest{
+		Env:     "${cfg:default}",
+		Default: "${cfg:env}",
+	}
+	err = interpolate(s, nil)
+	if !errors.Is(err, ErrInterpolation) || !strings.Contains(err.Error(), "env -> default -> env") {
+		t.Fatal("expected a cycle error but got", err)
+	}
+
+	structtag.Setup()
+	structtag.Tag = "env"
+	structtag.Prefix = "APP"
+	defer structtag.Setup()
+	for _, value := range []string{"${cfg:unknown}", "${GOCONFIG_TEST_HOST"} {
+		s = &interpolateTest{Env: value}
+		err = interpolate(s, nil)
+		if !errors.Is(err, ErrInterpolation) {
+			t.Fatalf("expected ErrInterpolation for %q but got %v", value, err)
+		}
+		if structtag.Tag != "env" || structtag.Prefix != "APP" {
+			t.Fatal("the structtag options must be restored")
+		}
+	}
+
+	// only the values set by the file are expanded
+	Interpolate = true
+	defer func() {
+		Interpolate = false
+	}()
+	s = &interpolateTest{Env: "${GOCONFIG_TEST_HOST}", Hosts: []string{"$a"}}
+	err = loadInterpolated(s, func() error {
+		s.Default = "${cfg:env}"
+		s.Mongo.Host = "${GOCONFIG_TEST_HOST}"
+		s.Hosts = append(s.Hosts, "${cfg:mongodb.host}")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = &interpolateTest{
+		Env:     "${GOCONFIG_TEST_HOST}",
+		Default: "${GOCONFIG_TEST_HOST}",
+		Mongo:   mongo{Host: "db.example.com"},
+		Hosts:   []string{"$a", "db.example.com"},
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expected %+v but got %+v", expected, s)
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	type server struct {
+		Host string `json:"host"`
+	}
+	type embedded struct {
+		Level string `json:"level"`
+	}
+	type unknownTest struct {
+		embedded
+		Name    string   `json:"name" cfgAlias:"title"`
+		Servers []server `json:"servers"`
+	}
+
+	m := map[string]interface{}{
+		"title": "x",
+		"level": "debug",
+		"nmae":  "x",
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a"},
+			map[string]interface{}{"hsot": "b"},
+		},
+	}
+	keyOf := func(field reflect.StructField) string {
+		return strings.Split(field.Tag.Get("json"), ",")[0]
+	}
+
+	keys := UnknownKeys(&unknownTest{}, m, keyOf)
+	if !reflect.DeepEqual(keys, []string{"nmae", "servers[1].hsot"}) {
+		t.Fatal("unexpected unknown keys", keys)
+	}
+
+	lines := map[string]int{"nmae": 4, "servers[1].hsot": 8}
+	err := UnknownKeysError(keys, func(key string) int {
+		return lines[key]
+	})
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Fatal("expected ErrUnknownKey but got", err)
+	}
+	expected := "unknown config file key: nmae (line 4), servers[1].hsot (line 8)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q but got %q", expected, err.Error())
+	}
+
+	if UnknownKeysError(nil, nil) != nil {
+		t.Fatal("no keys must not be an error")
+	}
+}
+
+type includeTest struct {
+	Values []string
+}
+
+// loadKV loads files of key=value lines, appending "file:key=value" to Values
+func loadKV(config interface{}) (err error) {
+	byt, err := os.ReadFile(filepath.Join(Path, File))
+	if err != nil {
+		return
+	}
+	m := make(map[string]interface{})
+	for _, line := range strings.Split(strings.TrimSpace(string(byt)), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		m[kv[0]] = kv[1]
+	}
+	err = Include(config, m)
+	if err != nil {
+		return
+	}
+	c := config.(*includeTest)
+	for _, line := range strings.Split(strings.TrimSpace(string(byt)), "\n") {
+		if !strings.HasPrefix(line, "include=") {
+			c.Values = append(c.Values, File+":"+line)
+		}
+	}
+	return
+}
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.kv":          "include=sub/a.kv, sub/b*.kv\nname=main",
+		"sub/a.kv":         "include=c.kv\nname=a",
+		"sub/b1.kv":        "name=b1",
+		"sub/c.kv":         "name=c",
+		"conf.d/20-z.kv":   "name=z",
+		"conf.d/10-y.kv":   "name=y",
+		"conf.d/README":    "ignored",
+		"cycle/one.kv":     "include=two.kv",
+		"cycle/two.kv":     "include=one.kv",
+		"missing/main.kv":  "include=none.kv",
+		"missing/empty.kv": "include=none*.kv",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	formats := Formats
+	Formats = []Fileformat{{Extension: ".kv", Load: loadKV, PrepareHelp: mPrepareHelp}}
+	DropInDir = "conf.d"
+	defer func() {
+		Formats = formats
+		DropInDir = ""
+		Path = "./"
+		File = ""
+	}()
+
+	Path, File = dir, "main.kv"
+	c := &includeTest{}
+	err := loadConfigFromFile(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"c.kv:name=c", "a.kv:name=a", "b1.kv:name=b1", "main.kv:name=main", "10-y.kv:name=y", "20-z.kv:name=z"}
+	if !reflect.DeepEqual(c.Values, expected) {
+		t.Fatalf("expected %q but got %q", expected, c.Values)
+	}
+	if Path != dir || File != "main.kv" {
+		t.Fatal("Path and File must be restored, got", Path, File)
+	}
+	if len(loadedFiles) != 6 {
+		t.Fatal("expected 6 loaded files but got", loadedFiles)
+	}
+
+	DropInDir = ""
+	Path, File = filepath.Join(dir, "cycle"), "one.kv"
+	err = loadConfigFromFile(&includeTest{})
+	if !errors.Is(err, ErrIncludeCycle) {
+		t.Fatal("expected ErrIncludeCycle but got", err)
+	}
+
+	Path, File = filepath.Join(dir, "missing"), "main.kv"
+	err = loadConfigFromFile(&includeTest{})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("expected os.ErrNotExist but got", err)
+	}
+
+	Path, File = filepath.Join(dir, "missing"), "empty.kv"
+	err = loadConfigFromFile(&includeTest{})
+	if err != nil {
+		t.Fatal("a pattern without matches must be ignored, got", err)
+	}
+}
+
+func TestIncludeFS(t *testing.T) {
+	decodeKV := func(r io.Reader, config interface{}) (err error) {
+		byt, err := io.ReadAll(r)
+		if err != nil {
+			return
+		}
+		m := make(map[string]interface{})
+		for _, line := range strings.Split(strings.TrimSpace(string(byt)), "\n") {
+			kv := strings.SplitN(line, "=", 2)
+			m[kv[0]] = kv[1]
+		}
+		err = Include(config, m)
+		if err != nil {
+			return
+		}
+		c := config.(*includeTest)
+		for _, line := range strings.Split(strings.TrimSpace(string(byt)), "\n") {
+			if !strings.HasPrefix(line, "include=") {
+				c.Values = append(c.Values, line)
+			}
+		}
+		return
+	}
+
+	formats := Formats
+	Formats = []Fileformat{{Extension: ".kv", Load: mLoad, PrepareHelp: mPrepareHelp, Decode: decodeKV}}
+	defer func() {
+		Formats = formats
+	}()
+
+	fsys := fstest.MapFS{
+		"conf/main.kv":  {Data: []byte("include=sub/a.kv, sub/b*.kv\nname=main")},
+		"conf/sub/a.kv": {Data: []byte("include=c.kv\nname=a")},
+		"conf/sub/b.kv": {Data: []byte("name=b")},
+		"conf/sub/c.kv": {Data: []byte("name=c")},
+		"cycle.kv":      {Data: []byte("include=cycle.kv")},
+		"missing.kv":    {Data: []byte("include=none.kv")},
+	}
+	c := &includeTest{}
+	err := LoadFS(c, fsys, "conf/main.kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"name=c", "name=a", "name=b", "name=main"}
+	if !reflect.DeepEqual(c.Values, expected) {
+		t.Fatalf("expected %q but got %q", expected, c.Values)
+	}
+
+	err = LoadFS(&includeTest{}, fsys, "cycle.kv")
+	if !errors.Is(err, ErrIncludeCycle) {
+		t.Fatal("expected ErrIncludeCycle but got", err)
+	}
+	err = LoadFS(&includeTest{}, fsys, "missing.kv")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist but got", err)
+	}
+
+	err = LoadBytes(&includeTest{}, []byte("include=a.kv\nname=main"), ".kv")
+	if !errors.Is(err, ErrIncludeNotSupported) {
+		t.Fatal("expected ErrIncludeNotSupported but got", err)
+	}
+	err = LoadBytes(&includeTest{}, []byte("include=\nname=main"), ".kv")
+	if err != nil {
+		t.Fatal("an empty include must be ignored, got", err)
+	}
+	if len(loading) != 0 {
+		t.Fatal("the loading stack must be empty, got", loading)
+	}
+}
+
+func TestLoadReader(t *testing.T) {
+	type readerTest struct {
+		Name string
+	}
+	decode := func(r io.Reader, config interface{}) (err error) {
+		byt, err := io.ReadAll(r)
+		if err != nil {
+			return
+		}
+		config.(*readerTest).Name = strings.TrimSpace(string(byt))
+		return
+	}
+
+	formats := Formats
+	Formats = []Fileformat{
+		{Extension: ".txt", Load: mLoad, PrepareHelp: mPrepareHelp, Decode: decode},
+		{Extension: ".old", Load: mLoad, PrepareHelp: mPrepareHelp},
+	}
+	defer func() {
+		Formats = formats
+	}()
+
+	c := &readerTest{}
+	err := LoadBytes(c, []byte("bytes\n"), ".txt")
+	if err != nil || c.Name != "bytes" {
+		t.Fatal("LoadBytes failed:", err, c.Name)
+	}
+
+	fsys := fstest.MapFS{"conf/app.txt": {Data: []byte("fs")}}
+	err = LoadFS(c, fsys, "conf/app.txt")
+	if err != nil || c.Name != "fs" {
+		t.Fatal("LoadFS failed:", err, c.Name)
+	}
+
+	err = LoadFS(c, fsys, "conf/missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("expected fs.ErrNotExist but got", err)
+	}
+
+	err = LoadBytes(c, nil, ".old")
+	if !errors.Is(err, ErrDecodeNotSupported) {
+		t.Fatal("expected ErrDecodeNotSupported but got", err)
+	}
+
+	err = LoadBytes(c, nil, ".unknown")
+	if !errors.Is(err, ErrFileFormatNotDefined) {
+		t.Fatal("expected ErrFileFormatNotDefined but got", err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	type saveTest struct {
+		Name string
+	}
+	encode := func(w io.Writer, config interface{}) (err error) {
+		_, err = io.WriteString(w, config.(*saveTest).Name)
+		return
+	}
+
+	formats := Formats
+	Formats = []Fileformat{
+		{Extension: ".txt", Load: mLoad, PrepareHelp: mPrepareHelp, Encode: encode},
+		{Extension: ".old", Load: mLoad, PrepareHelp: mPrepareHelp},
+	}
+	defer func() {
+		Formats = formats
+	}()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.txt")
+	err := Save(&saveTest{Name: "first"}, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(name)
+	if err != nil || info.Mode().Perm() != 0644 {
+		t.Fatal("expected a new file with mode 0644:", err, info.Mode())
+	}
+
+	err = os.Chmod(name, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Save(&saveTest{Name: "second"}, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byt, err := os.ReadFile(name)
+	if err != nil || string(byt) != "second" {
+		t.Fatal("unexpected content:", err, string(byt))
+	}
+	info, err = os.Stat(name)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatal("the mode must be preserved:", err, info.Mode())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatal("temporary files must be removed:", err, entries)
+	}
+
+	err = Save(&saveTest{}, filepath.Join(dir, "app.old"))
+	if !errors.Is(err, ErrEncodeNotSupported) {
+		t.Fatal("expected ErrEncodeNotSupported but got", err)
+	}
+}
+
+func TestValues(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+	type sub struct {
+		Host string `json:"host" cfgDefault:"localhost"`
+		Port int    `json:"port" cfgDefault:"27017"`
+	}
+	type valuesTest struct {
+		Ratio float64  `json:"ratio" cfgDefault:"1.50"`
+		Debug bool     `json:"debug" cfgDefault:"true"`
+		Name  string   `json:"name"`
+		Skip  string   `json:"-"`
+		Tags  []string `json:"tags"`
+		Items []item   `json:"items"`
+		Mongo sub      `json:"mongodb"`
+	}
+	keyOf := func(field reflect.StructField) string {
+		return strings.Split(field.Tag.Get("json"), ",")[0]
+	}
+	config := &valuesTest{
+		Ratio: 1.5,
+		Debug: false,
+		Items: []item{{Name: "a"}},
+		Mongo: sub{Host: "localhost", Port: 27017},
+	}
+
+	SaveOmitDefaults = true
+	defer func() {
+		SaveOmitDefaults = false
+	}()
+	m := Values(config, keyOf)
+	expected := map[string]interface{}{
+		"debug": false,
+		"items": []interface{}{map[string]interface{}{"name": "a"}},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("expected %#v but got %#v", expected, m)
+	}
+
+	SaveOmitDefaults = false
+	m = Values(config, keyOf)
+	if len(m) != 6 || m["ratio"] != 1.5 || m["tags"] == nil {
+		t.Fatalf("all values expected but got %#v", m)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	type sub struct {
+		Host string `cfg:"host"`
+		Port int    `cfg:"port"`
+	}
+	type patchTest struct {
+		Name    string        `cfg:"name"`
+		Timeout time.Duration `cfg:"timeout"`
+		Mongo   sub           `cfg:"mongodb"`
+	}
+	var got []Edit
+	patch := func(doc []byte, edits []Edit) (ret []byte, err error) {
+		got = edits
+		ret = append(doc, "patched"...)
+		return
+	}
+	decode := func(r io.Reader, config interface{}) (err error) {
+		byt, err := io.ReadAll(r)
+		config.(*patchTest).Name = string(byt)
+		return
+	}
+	encode := func(w io.Writer, config interface{}) (err error) {
+		_, err = io.WriteString(w, config.(*patchTest).Name+" "+config.(*patchTest).Timeout.String())
+		return
+	}
+	keyOf := func(field reflect.StructField) string {
+		return strings.ToUpper(field.Tag.Get(Tag))
+	}
+
+	formats := Formats
+	Formats = []Fileformat{
+		{Extension: ".p", Load: mLoad, PrepareHelp: mPrepareHelp, KeyOf: keyOf, Patch: patch},
+		{Extension: ".txt", Load: mLoad, PrepareHelp: mPrepareHelp, Decode: decode, Encode: encode},
+	}
+	defer func() {
+		Formats = formats
+	}()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.p")
+	err := os.WriteFile(name, []byte("# keep\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &patchTest{}
+	err = Patch(config, name, map[string]interface{}{
+		"name":         "app",
+		"mongodb.port": "27017",
+		"timeout":      "5s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Edit{
+		{Keys: []string{"MONGODB", "PORT"}, Value: 27017},
+		{Keys: []string{"NAME"}, Value: "app"},
+		{Keys: []string{"TIMEOUT"}, Value: 5 * time.Second},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %#v but got %#v", expected, got)
+	}
+	byt, err := os.ReadFile(name)
+	if err != nil || string(byt) != "# keep\npatched" {
+		t.Fatal("unexpected content:", err, string(byt))
+	}
+	if config.Mongo.Port != 27017 || config.Timeout != 5*time.Second {
+		t.Fatalf("config must be updated: %#v", config)
+	}
+
+	name = filepath.Join(dir, "app.txt")
+	err = os.WriteFile(name, []byte("old"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Patch(&patchTest{}, name, map[string]interface{}{"timeout": time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byt, err = os.ReadFile(name)
+	if err != nil || string(byt) != "old 1m0s" {
+		t.Fatal("expected the file to be saved whole but got:", err, string(byt))
+	}
+
+	for _, values := range []map[string]interface{}{
+		{"mongodb.user": "root"},
+		{"name.first": "app"},
+		{"mongodb.port": "port"},
+		{"mongodb.port": true},
+		{"mongodb.port": 8080.5},
+		{"timeout": 1.5},
+		{"name": 42},
+		{"mongodb.host": 'a'},
+	} {
+		err = Patch(&patchTest{}, name, values)
+		if !errors.Is(err, ErrPatch) {
+			t.Fatalf("expected ErrPatch for %v but got %v", values, err)
+		}
+	}
+
+	// the integers convert to the integer fields
+	config = &patchTest{}
+	err = Patch(config, name, map[string]interface{}{"mongodb.port": int64(8080), "timeout": int32(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Mongo.Port != 8080 || config.Timeout != 10 {
+		t.Fatalf("unexpected config %#v", config)
+	}
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		doc      string
+		expected string
+	}{
+		{"\n  {\"name\": \"app\"}", ".json"},
+		{"---\nname: app\n", ".yaml"},
+		{"# app\nname: app\nmongodb:\n  host: localhost\n", ".yaml"},
+		{"title = \"app\"\n\n[mongodb]\nport = 27017\n", ".toml"},
+		{"[[servers]]\nhost = \"a\"\n", ".toml"},
+		{"; app\n[mongodb]\nhost = localhost\n", ".ini"},
+		{"name=app\n", ".ini"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		exts := sniff([]byte(test.doc))
+		got := ""
+		if len(exts) > 0 {
+			got = exts[0]
+		}
+		if got != test.expected {
+			t.Errorf("expected %q for %q but got %v", test.expected, test.doc, exts)
+		}
+	}
+}
+
+func TestConfigFormat(t *testing.T) {
+	type formatTest struct {
+		Name string `cfg:"name"`
+	}
+	var loaded string
+	load := func(ext string) func(config interface{}) error {
+		return func(config interface{}) (err error) {
+			loaded = ext
+			return
+		}
+	}
+	formats := Formats
+	Formats = []Fileformat{
+		{Extension: ".yaml", Load: load(".yaml"), PrepareHelp: mPrepareHelp},
+		{Extension: ".toml", Load: load(".toml"), PrepareHelp: mPrepareHelp},
+	}
+	dir := t.TempDir()
+	defer func() {
+		Formats = formats
+		Format = ""
+		SniffFormat = false
+		Path = "./"
+		File = ""
+	}()
+
+	err := os.WriteFile(filepath.Join(dir, "config"), []byte("[mongodb]\nport = 1\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Path, File = dir, "config"
+	if hasConfigFile() {
+		t.Fatal("a file without extension must be skipped without Format or SniffFormat")
+	}
+
+	SniffFormat = true
+	err = loadConfigFromFile(&formatTest{})
+	if err != nil || loaded != ".toml" {
+		t.Fatal("expected the toml format to be sniffed:", err, loaded)
+	}
+
+	os.Setenv("GO_CONFIG_FORMAT", "yaml")
+	defer os.Unsetenv("GO_CONFIG_FORMAT")
+	lookupEnv()
+	err = loadConfigFromFile(&formatTest{})
+	if err != nil || loaded != ".yaml" {
+		t.Fatal("expected the format set by GO_CONFIG_FORMAT:", err, loaded)
+	}
+
+	Format = "json"
+	err = loadConfigFromFile(&formatTest{})
+	if !errors.Is(err, ErrFileFormatNotDefined) {
+		t.Fatal("expected ErrFileFormatNotDefined but got", err)
+	}
+
+	Format, File = "", "missing"
+	err = loadConfigFromFile(&formatTest{})
+	if err != nil {
+		t.Fatal("a missing file must be ignored unless FileRequired:", err)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	formats := Formats
+	Formats = nil
+	defer func() {
+		Formats = formats
+	}()
+
+	yaml := Fileformat{
+		Extension:   ".yaml",
+		Extensions:  []string{".yml"},
+		MIMETypes:   []string{"application/yaml"},
+		Comments:    true,
+		Load:        mLoad,
+		PrepareHelp: mPrepareHelp,
+		Encode:      func(w io.Writer, config interface{}) error { return nil },
+	}
+	err := RegisterFormat(yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"yaml", "YAML", ".yml", "yml", "application/yaml; charset=utf-8"} {
+		f, ok := LookupFormat(key)
+		if !ok || f.Name != "yaml" {
+			t.Fatalf("expected the yaml format for %q but got %#v", key, f.Name)
+		}
+	}
+	if _, err = findFileFormat(".yml"); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := LookupFormat("yaml")
+	if !f.Has(CanEncode|SupportsComments) || f.Has(CanDecode) || f.Has(CanPatch) {
+		t.Fatalf("unexpected capabilities %b", f.Capabilities())
+	}
+
+	other := Fileformat{Name: "yaml2", Extension: ".yml", Load: mLoad, PrepareHelp: mPrepareHelp}
+	err = RegisterFormat(other)
+	if !errors.Is(err, ErrFormatConflict) {
+		t.Fatal("expected ErrFormatConflict but got", err)
+	}
+	err = RegisterFormat(Fileformat{Name: "none"})
+	if !errors.Is(err, ErrFormatInvalid) {
+		t.Fatal("expected ErrFormatInvalid but got", err)
+	}
+
+	err = ReplaceFormat(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(Formats) != 1 || Formats[0].Name != "yaml2" {
+		t.Fatalf("expected yaml to be replaced but got %v formats", len(Formats))
+	}
+	if _, ok := LookupFormat(".yaml"); ok {
+		t.Fatal("the extensions of the replaced format must be removed")
+	}
+
+	if !UnregisterFormat("yaml2") || UnregisterFormat("yaml2") || len(Formats) != 0 {
+		t.Fatal("expected yaml2 to be unregistered once")
+	}
+}
+
+func TestRegisterFormatPriority(t *testing.T) {
+	formats := Formats
+	defer func() {
+		Formats = formats
+	}()
+
+	builtin := Fileformat{Name: "yaml", Extension: ".yaml", Extensions: []string{".yml"}, Load: mLoad, PrepareHelp: mPrepareHelp}
+	custom := Fileformat{Name: "fastyaml", Extension: ".yml", Priority: 1, Load: mLoad, PrepareHelp: mPrepareHelp}
+	for _, order := range [][]Fileformat{{builtin, custom}, {custom, builtin}} {
+		Formats = nil
+		for _, f := range order {
+			err := RegisterFormat(f)
+			if err != nil {
+				t.Fatalf("registering %v: %v", f.Name, err)
+			}
+		}
+		if len(Formats) != 1 || Formats[0].Name != "fastyaml" {
+			t.Fatalf("expected the higher priority format only but got %v formats", len(Formats))
+		}
+		if f, ok := LookupFormat(".yml"); !ok || f.Name != "fastyaml" {
+			t.Fatal("expected fastyaml for .yml")
+		}
+		if _, ok := LookupFormat(".yaml"); ok {
+			t.Fatal("the lower priority format must not be registered")
+		}
+	}
+
+	Formats = []Fileformat{builtin}
+	custom.Priority = 0
+	err := RegisterFormat(custom)
+	if !errors.Is(err, ErrFormatConflict) {
+		t.Fatal("expected ErrFormatConflict for the same priority but got", err)
+	}
+}
@@ -2,96 +2,186 @@ package env
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
-	"strconv"
 	"strings"
 
 	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/goenv"
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/structtag"
 	"github.com/joho/godotenv"
 )
 
 func init() {
-	goconfig.Formats = append(goconfig.Formats, goconfig.Fileformat{
+	err := goconfig.RegisterFormat(goconfig.Fileformat{
+		Name:        "env",
 		Extension:   ".env",
+		Comments:    true,
 		Load:        LoadEnv,
+		Decode:      DecodeEnv,
+		Encode:      EncodeEnv,
 		PrepareHelp: PrepareHelp,
 	})
+	if err != nil {
+		panic(err)
+	}
 }
 
-func LoadEnv(config interface{}) error {
+// LoadEnv config file, the variables are named like the environment
+// variables read by goconfig, including PrefixEnv and nested fields
+func LoadEnv(config interface{}) (err error) {
 	configFile := filepath.Join(goconfig.Path, goconfig.File)
+	file, err := os.Open(configFile)
+	if err != nil {
+		if os.IsNotExist(err) && !goconfig.FileRequired {
+			err = nil
+		}
+		return
+	}
+	defer helper.Closer(file)
 
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
-		// If config does not exist, just continue.
-		return nil
+	err = DecodeEnv(file, config)
+	return
+}
+
+// DecodeEnv reads the .env document from r into config
+func DecodeEnv(r io.Reader, config interface{}) (err error) {
+	dotEnvMap, err := godotenv.Parse(r)
+	if err != nil {
+		return
 	}
 
-	dotEnvMap, err := godotenv.Read(configFile)
+	err = goconfig.Include(config, dotEnvMap)
 	if err != nil {
-		return err
+		return
 	}
 
-	configType := reflect.TypeOf(config).Elem()
-	configValue := reflect.ValueOf(config).Elem()
+	setupEnv()
+	output := goenv.PrintDefaultsOutput
+	defer func() {
+		goenv.Lookup = os.LookupEnv
+		goenv.PrintDefaultsOutput = output
+	}()
+	goenv.Lookup = func(name string) (value string, ok bool) {
+		value, ok = dotEnvMap[name]
+		return
+	}
+	err = goenv.Parse(config)
+	if err != nil {
+		return
+	}
+	err = decodeLegacyKeys(config, dotEnvMap)
+	return
+}
 
+// decodeLegacyKeys reads the top level fields still named by the env tag, the
+// cfg tag or the upper case field name, warning that the keys are deprecated
+func decodeLegacyKeys(config interface{}, dotEnvMap map[string]string) (err error) {
+	type fieldKey struct {
+		addr uintptr
+		typ  reflect.Type
+	}
+	list, err := goenv.Names(config)
+	if err != nil {
+		return
+	}
+	names := make(map[string]bool, len(list))
+	for _, name := range list {
+		names[name] = true
+	}
+	fieldNames := make(map[fieldKey]string)
+	err = goenv.Walk(config, func(field *reflect.StructField, value *reflect.Value, name string) (err error) {
+		fieldNames[fieldKey{value.UnsafeAddr(), value.Type()}] = name
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	configValue := reflect.ValueOf(config).Elem()
+	configType := configValue.Type()
 	for i := 0; i < configType.NumField(); i++ {
 		field := configType.Field(i)
-
-		confKey := getConfKey(field)
-		if confKey == "-" {
+		value := configValue.Field(i)
+		name, ok := fieldNames[fieldKey{value.UnsafeAddr(), value.Type()}]
+		if !ok {
 			continue
 		}
-
-		value, ok := dotEnvMap[confKey]
-		if !ok {
+		key := legacyKey(field)
+		s, ok := dotEnvMap[key]
+		if !ok || names[key] {
+			continue
+		}
+		if _, ok = dotEnvMap[name]; ok {
 			continue
 		}
-		switch field.Type.Kind() {
-		case reflect.String:
-			configValue.Field(i).SetString(value)
-		case reflect.Int:
-			intValue, err := strconv.Atoi(value)
-			if err != nil {
-				return fmt.Errorf("failed to parse int value for field %s: %v", field.Name, err)
-			}
-			configValue.Field(i).SetInt(int64(intValue))
-		case reflect.Bool:
-			boolValue, err := strconv.ParseBool(value)
-			if err != nil {
-				return fmt.Errorf("failed to parse bool value for field %s: %v", field.Name, err)
-			}
-			configValue.Field(i).SetBool(boolValue)
-		default:
-			return fmt.Errorf("unsupported field type: %v", field.Type.Kind())
+		err = helper.ParseValue(value, s)
+		if err != nil {
+			err = fmt.Errorf("field %v, key %v: %w", field.Name, key, err)
+			return
 		}
+		goconfig.Warn(structtag.Deprecation(&field, "key "+key, name))
 	}
-
-	return nil
+	return
 }
 
-func PrepareHelp(config interface{}) (string, error) {
-	var helpAux []byte
-	configValue := reflect.ValueOf(config).Elem()
-	for i := 0; i < configValue.NumField(); i++ {
-		confKey := getConfKey(configValue.Type().Field(i))
-		if confKey == "-" {
-			continue
-		}
-
-		helpAux = append(helpAux, []byte(strings.ToUpper(confKey)+"=value\n")...)
+// legacyKey returns the key of field in the .env files read before they
+// were named like the environment variables
+func legacyKey(field reflect.StructField) (key string) {
+	key = field.Tag.Get("env")
+	if key == "" {
+		key = field.Tag.Get(goconfig.Tag)
 	}
-	return string(helpAux), nil
+	if key == "" {
+		key = strings.ToUpper(field.Name)
+	}
+	return
 }
 
-func getConfKey(field reflect.StructField) string {
-	k := field.Tag.Get("env")
-	if k == "" {
-		k = field.Tag.Get("cfg")
+// EncodeEnv writes config as NAME=value lines to w, quoting the values
+func EncodeEnv(w io.Writer, config interface{}) (err error) {
+	setupEnv()
+	goenv.OmitDefaults = goconfig.SaveOmitDefaults
+	defer func() {
+		goenv.OmitDefaults = false
+	}()
+	env, err := goenv.Environ(config)
+	if err != nil {
+		return
+	}
+	m := make(map[string]string, len(env))
+	for _, kv := range env {
+		pair := strings.SplitN(kv, "=", 2)
+		m[pair[0]] = pair[1]
+	}
+	content, err := godotenv.Marshal(m)
+	if err != nil {
+		return
 	}
-	if k == "" {
-		k = strings.ToUpper(field.Name)
+	_, err = io.WriteString(w, content+"\n")
+	return
+}
+
+// PrepareHelp return help string for this file format.
+func PrepareHelp(config interface{}) (help string, err error) {
+	setupEnv()
+	env, err := goenv.Environ(config)
+	if err != nil {
+		return
 	}
-	return k
+	help = strings.Join(env, "\n") + "\n"
+	return
+}
+
+// setupEnv prepares goenv with the options of goconfig
+func setupEnv() {
+	goenv.Prefix = goconfig.PrefixEnv
+	goenv.Naming = goconfig.EnvNaming
+	goenv.AtFile = goconfig.AtFileValues
+	goenv.Files = goconfig.EnvFiles
+	goenv.Setup(goconfig.Tag, goconfig.TagDefault, goconfig.KebabCfgToSnakeEnv)
+	goenv.Warn = goconfig.Warn
 }
@@ -0,0 +1,168 @@
+package env
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/helper"
+)
+
+type mongoDB struct {
+	Host string `cfg:"host" cfgDefault:"localhost"`
+	Port int    `cfg:"port" cfgDefault:"27017"`
+}
+
+type testConfig struct {
+	Name    string        `cfg:"name"`
+	Count   int           `cfg:"count"`
+	Big     int64         `cfg:"big"`
+	Ratio   float64       `cfg:"ratio"`
+	Debug   bool          `cfg:"debug"`
+	Timeout time.Duration `cfg:"timeout"`
+	Hosts   []string      `cfg:"hosts"`
+	Ports   []int         `cfg:"ports"`
+	MongoDB mongoDB       `cfg:"mongodb"`
+}
+
+func TestDecodeEnv(t *testing.T) {
+	doc := `# comment
+NAME="a name"
+COUNT=-3
+BIG=1099511627776
+RATIO=0.5
+DEBUG=true
+TIMEOUT=1500
+HOSTS=a,b
+PORTS=80,443
+MONGODB_HOST=db
+`
+	c := &testConfig{}
+	err := DecodeEnv(strings.NewReader(doc), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &testConfig{
+		Name:    "a name",
+		Count:   -3,
+		Big:     1 << 40,
+		Ratio:   0.5,
+		Debug:   true,
+		Timeout: 1500,
+		Hosts:   []string{"a", "b"},
+		Ports:   []int{80, 443},
+		MongoDB: mongoDB{Host: "db", Port: 27017},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, c)
+	}
+}
+
+func TestDecodeEnvPrefix(t *testing.T) {
+	goconfig.PrefixEnv = "APP"
+	defer func() {
+		goconfig.PrefixEnv = ""
+	}()
+	c := &testConfig{}
+	err := DecodeEnv(strings.NewReader("APP_MONGODB_PORT=1\nMONGODB_HOST=ignored\n"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MongoDB.Port != 1 || c.MongoDB.Host != "localhost" {
+		t.Fatalf("unexpected mongodb %+v", c.MongoDB)
+	}
+}
+
+func TestDecodeEnvError(t *testing.T) {
+	c := &testConfig{}
+	err := DecodeEnv(strings.NewReader("COUNT=many\n"), c)
+	if err == nil {
+		t.Fatal("expected an error for COUNT=many")
+	}
+}
+
+type legacyConfig struct {
+	Host  string   `env:"DB_HOST" cfg:"host"`
+	Port  int      `cfg:"port"`
+	Debug bool     `env:"VERBOSE"`
+	Tags  []string `env:"LABELS" cfg:"tags"`
+}
+
+func TestDecodeEnvLegacyKeys(t *testing.T) {
+	var warnings []string
+	goconfig.Warn = func(msg string) {
+		warnings = append(warnings, msg)
+	}
+	defer func() {
+		goconfig.Warn = helper.Warn
+	}()
+
+	doc := "DB_HOST=db\nport=8080\nVERBOSE=true\nLABELS=a,b\n"
+	c := &legacyConfig{}
+	err := DecodeEnv(strings.NewReader(doc), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &legacyConfig{Host: "db", Port: 8080, Debug: true, Tags: []string{"a", "b"}}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, c)
+	}
+	if len(warnings) != 4 || warnings[0] != "key DB_HOST is deprecated, use HOST" {
+		t.Fatal("unexpected warnings", warnings)
+	}
+
+	// the new name wins over the old key
+	warnings = nil
+	c = &legacyConfig{}
+	err = DecodeEnv(strings.NewReader("DB_HOST=old\nHOST=new\n"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "new" || len(warnings) != 0 {
+		t.Fatalf("expected HOST=new without warnings, got %q and %v", c.Host, warnings)
+	}
+}
+
+func TestEncodeEnv(t *testing.T) {
+	c := &testConfig{
+		Name:    "a name",
+		Count:   2,
+		Ratio:   0.25,
+		Hosts:   []string{"a", "b"},
+		MongoDB: mongoDB{Host: "db", Port: 1},
+	}
+	var buf bytes.Buffer
+	err := EncodeEnv(&buf, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`NAME="a name"`, "COUNT=2", `HOSTS="a,b"`, `MONGODB_HOST="db"`, "MONGODB_PORT=1"} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("expected %v in\n%v", line, buf.String())
+		}
+	}
+
+	decoded := &testConfig{}
+	err = DecodeEnv(&buf, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, decoded) {
+		t.Fatalf("expected %+v, got %+v", c, decoded)
+	}
+}
+
+func TestPrepareHelp(t *testing.T) {
+	help, err := PrepareHelp(&testConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"NAME=", "HOSTS=", "MONGODB_HOST=", "MONGODB_PORT=0"} {
+		if !strings.Contains(help, line+"\n") {
+			t.Fatalf("expected %v in\n%v", line, help)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Example with configuration file.
+*/
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/h2oai/goconfig"
+	_ "github.com/h2oai/goconfig/properties"
+)
+
+type mongoDB struct {
+	Host string `cfg:"host" cfgDefault:"example.com" cfgHelper:"database host"`
+	Port int    `cfg:"port" cfgDefault:"999"`
+}
+
+type systemUser struct {
+	Name     string `cfg:"name"`
+	Password string `cfg:"passwd"`
+}
+
+type configTest struct {
+	DebugMode bool `cfg:"debug" cfgDefault:"false"`
+	Domain    string
+	Tags      []string   `cfg:"tags"`
+	User      systemUser `cfg:"user"`
+	MongoDB   mongoDB    `cfg:"mongodb"`
+}
+
+func main() {
+	config := configTest{}
+
+	goconfig.File = "config.properties"
+	err := goconfig.Parse(&config)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+
+	// just print struct on screen
+	j, _ := json.MarshalIndent(config, "", "\t")
+	println(string(j))
+}
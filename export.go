@@ -0,0 +1,52 @@
+package goconfig
+
+import (
+	"github.com/h2oai/goconfig/goenv"
+	"github.com/h2oai/goconfig/goflags"
+)
+
+// Environ returns the NAME=value pairs, named with PrefixEnv, that make
+// Parse read back the current values of config, like the environment of a
+// child process sharing the configuration. The variables of the subcommands
+// are included.
+func Environ(config interface{}) (env []string, err error) {
+	env, err = environ(config, PrefixEnv)
+	return
+}
+
+func environ(config interface{}, prefix string) (env []string, err error) {
+	setupEnv(prefix)
+	env, err = goenv.Environ(config)
+	if err != nil {
+		return
+	}
+
+	list, err := listCommands(config)
+	if err != nil {
+		return
+	}
+	for _, cmd := range list {
+		var sub []string
+		sub, err = environ(cmd.Value.Addr().Interface(), cmd.prefixEnv(prefix))
+		if err != nil {
+			return
+		}
+		env = append(env, sub...)
+	}
+	return
+}
+
+// Args returns the command line arguments, named with PrefixFlag, that make
+// Parse read back the current values of config. Subcommands are not included.
+func Args(config interface{}) (args []string, err error) {
+	goflags.Prefix = PrefixFlag
+	goflags.Separator = FlagSeparator
+	goflags.DoubleDash = DoubleDashFlags
+	goflags.Naming = FlagNaming
+	goflags.AtFile = AtFileValues
+	goflags.SetTag(Tag)
+	goflags.SetTagDefault(TagDefault)
+	goflags.SetTagHelper(TagHelper)
+	args, err = goflags.Args(config)
+	return
+}
@@ -0,0 +1,121 @@
+package goconfig
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FileKeyOf returns keyOf, or with CfgFileKeys set a function returning the
+// cfg tag of the fields and keyOf for the fields without one. The cfg tags
+// and the names of the fields without a tag are changed by FileNaming. The
+// file formats use it for the keys of the documents.
+func FileKeyOf(keyOf func(field reflect.StructField) string) func(field reflect.StructField) string {
+	if !RenamedFileKeys() {
+		return keyOf
+	}
+	return func(field reflect.StructField) (key string) {
+		if CfgFileKeys {
+			key = field.Tag.Get(Tag)
+		}
+		if key == "" {
+			key = keyOf(field)
+			// the names set by the tag of the format and the embedded
+			// structs are kept
+			if FileNaming == nil || field.Anonymous || key != field.Name && key != strings.ToLower(field.Name) {
+				return
+			}
+			key = field.Name
+		}
+		key = FileKey(key)
+		return
+	}
+}
+
+// FileKey returns name changed by FileNaming, used by the file formats
+// that name the keys after the cfg tags
+func FileKey(name string) string {
+	if FileNaming == nil || name == "" || name == "-" {
+		return name
+	}
+	return FileNaming(name)
+}
+
+// RenamedFileKeys returns true when CfgFileKeys or FileNaming change the
+// keys of the file formats from the names given by their own tags
+func RenamedFileKeys() bool {
+	return CfgFileKeys || FileNaming != nil
+}
+
+// RenameFileKeys is used by the file formats decoded by a library that reads
+// its own tag. With CfgFileKeys or FileNaming set it moves the values found
+// in the decoded document m under the key of their field returned by
+// FileKeyOf(keyOf) to the key returned by keyOf. It returns true when m
+// changed.
+func RenameFileKeys(config interface{}, m interface{}, keyOf func(field reflect.StructField) string) (renamed bool) {
+	if !RenamedFileKeys() {
+		return
+	}
+	renamed = renameFileKeys(reflect.TypeOf(config), reflect.ValueOf(m), FileKeyOf(keyOf), keyOf)
+	return
+}
+
+func renameFileKeys(t reflect.Type, m reflect.Value, from, to func(field reflect.StructField) string) (renamed bool) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for m.Kind() == reflect.Interface || m.Kind() == reflect.Ptr {
+		m = m.Elem()
+	}
+
+	switch m.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < m.Len(); i++ {
+			renamed = renameFileKeys(t, m.Index(i), from, to) || renamed
+		}
+		return
+	case reflect.Map:
+	default:
+		return
+	}
+
+	// the keys are moved after the lookups, so fields can swap their names
+	type move struct {
+		key   reflect.Value
+		value reflect.Value
+		to    string
+	}
+	var moves []move
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && to(field) == field.Name {
+			renamed = renameFileKeys(field.Type, m, from, to) || renamed
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		key, libKey := from(field), to(field)
+		if key == "" || key == "-" || libKey == "" || libKey == "-" {
+			continue
+		}
+		k, ok := lookupKey(m, key)
+		if !ok {
+			continue
+		}
+		renamed = renameFileKeys(field.Type, m.MapIndex(k), from, to) || renamed
+		if !strings.EqualFold(key, libKey) {
+			moves = append(moves, move{key: k, value: m.MapIndex(k), to: libKey})
+		}
+	}
+	for _, mv := range moves {
+		m.SetMapIndex(mv.key, reflect.Value{})
+	}
+	for _, mv := range moves {
+		m.SetMapIndex(reflect.ValueOf(mv.to).Convert(m.Type().Key()), mv.value)
+	}
+	renamed = renamed || len(moves) > 0
+	return
+}
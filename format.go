@@ -0,0 +1,143 @@
+package goconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Format of the config file, a name, extension or MIME type known by
+	// LookupFormat like "yaml", used instead of the extension of File
+	Format string
+
+	// SniffFormat detects the format of the files with no extension or an
+	// unknown one from their content
+	SniffFormat bool
+
+	sniffHeader    = regexp.MustCompile(`^\[\[?[^\[\]]+\]\]?\s*([#;].*)?$`)
+	sniffKeyValue  = regexp.MustCompile(`^[\w"'.\- ]+?\s*=\s*(.*)$`)
+	sniffYAMLKey   = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#:=\[{][^:=]*?):(\s|$)`)
+	sniffTOMLValue = regexp.MustCompile(`^("|'|\[|\{|true\b|false\b|[+-]?(\d|inf\b|nan\b))`)
+)
+
+// hasConfigFile reports whether File is set with a known way to find its format
+func hasConfigFile() bool {
+	return File != "" && (path.Ext(File) != "" || Format != "" || SniffFormat)
+}
+
+// configFormat returns the format of the config file, set by Format or found
+// like fileFormat
+func configFormat() (format Fileformat, err error) {
+	if Format != "" {
+		format, err = namedFormat()
+		return
+	}
+	format, err = fileFormat(filepath.Join(Path, File))
+	return
+}
+
+// fileFormat returns the format matching the extension of the file name,
+// then the one set by Format and, with SniffFormat, the one detected in its
+// content
+func fileFormat(name string) (format Fileformat, err error) {
+	format, err = findFileFormat(filepath.Ext(name))
+	if err == nil {
+		return
+	}
+	if Format != "" {
+		format, err = namedFormat()
+		return
+	}
+	if !SniffFormat {
+		return
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	head := make([]byte, 4096)
+	n, err := io.ReadFull(file, head)
+	file.Close()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	for _, ext := range sniff(head[:n]) {
+		format, err = findFileFormat(ext)
+		if err == nil {
+			return
+		}
+	}
+	err = fmt.Errorf("%v: %w", name, ErrFileFormatNotDefined)
+	return
+}
+
+// namedFormat returns the format registered for Format
+func namedFormat() (format Fileformat, err error) {
+	format, ok := LookupFormat(Format)
+	if !ok {
+		err = fmt.Errorf("%v: %w", Format, ErrFileFormatNotDefined)
+	}
+	return
+}
+
+// sniff returns the extensions of the formats byt looks like, the most
+// likely first
+func sniff(byt []byte) (exts []string) {
+	byt = bytes.TrimPrefix(byt, []byte("\xef\xbb\xbf"))
+	var headers, tomlValues, iniValues, yamlKeys bool
+	scanner := bufio.NewScanner(bytes.NewReader(byt))
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if first {
+			first = false
+			if strings.HasPrefix(line, "{") {
+				return []string{".json", ".yaml"}
+			}
+			if line == "---" || strings.HasPrefix(line, "%YAML") {
+				return []string{".yaml"}
+			}
+		}
+		switch {
+		case sniffHeader.MatchString(line):
+			headers = true
+			if strings.HasPrefix(line, "[[") {
+				tomlValues = true
+			}
+		case sniffYAMLKey.MatchString(line) || strings.HasPrefix(line, "- "):
+			yamlKeys = true
+		default:
+			if m := sniffKeyValue.FindStringSubmatch(line); m != nil {
+				if sniffTOMLValue.MatchString(m[1]) {
+					tomlValues = true
+				} else {
+					iniValues = true
+				}
+			}
+		}
+	}
+
+	switch {
+	case yamlKeys && !headers && !tomlValues && !iniValues:
+		exts = []string{".yaml"}
+	case tomlValues && !iniValues:
+		exts = []string{".toml", ".ini"}
+	case headers || iniValues:
+		exts = []string{".ini", ".toml"}
+	}
+	return
+}
@@ -2,12 +2,15 @@ package goenv
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
 
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/naming"
 	"github.com/h2oai/goconfig/structtag"
 )
 
@@ -20,17 +23,40 @@ var (
 
 	// PrintDefaultsOutput changes the default output help string
 	PrintDefaultsOutput string
+
+	// AtFile reads every value starting with @ from the file named after it, like @/etc/ca.pem, fields can also enable it with the cfgAtFile tag
+	AtFile bool
+
+	// Files reads every variable from the file named by <VAR>_FILE, like the secrets mounted by Docker and Kubernetes, fields can also enable it with the cfgSecret tag
+	Files bool
+
+	// FileSuffix is added to the variable name to find the file holding its value
+	FileSuffix = "_FILE"
+
+	// Warn is the function called to report the use of deprecated variables, can be replaced by your own version.
+	Warn = helper.Warn
+
+	// OmitDefaults makes Environ leave out the values equal to their default
+	OmitDefaults bool
+
+	// Lookup returns the value of a variable, replaced to read the variables from other sources like .env files
+	Lookup = os.LookupEnv
+
+	// Naming maps the field names and the cfg tags to the variable names, like naming.ScreamingSnake, the names are uppercased when nil
+	Naming naming.Mapper
 )
 
-// Setup maps and variables
-func Setup(tag string, tagDefault string, kebabCfgToSnakeEnv bool) {
+// Setup maps and variables, kebabCfgToSnakeEnv is optional and sets
+// SetKebabCfgToSnakeEnv
+func Setup(tag string, tagDefault string, kebabCfgToSnakeEnv ...bool) {
 	Usage = DefaultUsage
 
 	structtag.Setup()
 	structtag.Prefix = Prefix
+	structtag.Naming = Naming
 	SetTag(tag)
 	SetTagDefault(tagDefault)
-	SetKebabCfgToSnakeEnv(kebabCfgToSnakeEnv)
+	SetKebabCfgToSnakeEnv(len(kebabCfgToSnakeEnv) > 0 && kebabCfgToSnakeEnv[0])
 
 	structtag.ParseMap[reflect.Int64] = reflectInt
 	structtag.ParseMap[reflect.Int] = reflectInt
@@ -52,6 +78,8 @@ func SetTagDefault(tag string) {
 }
 
 // SetKebabCfgToSnakeEnv set a new CfgToSnakeEnv to look for snakecase environment variables
+//
+// Deprecated: set Naming to naming.ScreamingSnake instead
 func SetKebabCfgToSnakeEnv(cfgToSnakeEnv bool) {
 	structtag.KebabCfgToSnakeEnv = cfgToSnakeEnv
 }
@@ -76,32 +104,123 @@ func parseValue(datatype string, value *reflect.Value) (ret string, ok bool) {
 	case "float64":
 		ret = strconv.FormatFloat(value.Float(), 'f', -1, 64)
 		ok = ret != "0"
+	case "list":
+		// the current items are kept as they are, formatting and splitting
+		// them again would change the items holding commas or spaces
+		ok = value.Len() > 0
 	}
 	return
 }
 
-func getNewValue(field *reflect.StructField, value *reflect.Value, tag string, datatype string) (ret string) {
-	defaultValue := field.Tag.Get(structtag.TagDefault)
-
+// envName returns the environment variable name of tag
+func envName(tag string) string {
+	if Naming != nil {
+		return tag
+	}
 	tag = strings.ToUpper(tag)
 	if structtag.KebabCfgToSnakeEnv {
 		tag = strings.Replace(tag, "-", "_", -1)
 	}
+	return tag
+}
 
-	sysvar := `$` + tag
+// sysvar returns the environment variable as written in the shell
+func sysvar(name string) string {
 	if runtime.GOOS == "windows" {
-		sysvar = `%` + tag + `%`
+		return `%` + name + `%`
+	}
+	return `$` + name
+}
+
+// lookupEnv returns the value of the environment variable name or of one of
+// the old names of the field
+func lookupEnv(field *reflect.StructField, tag, name string) (ret string, ok bool) {
+	ret, ok = Lookup(name)
+	if ok {
+		if structtag.IsDeprecated(field) {
+			Warn(structtag.Deprecation(field, sysvar(name), ""))
+		}
+		return
 	}
 
-	output := fmt.Sprintf("  %v %v\n\n", sysvar, datatype)
-	if defaultValue != "" {
-		output = fmt.Sprintf("  %v %v\n\t(default %q)\n", sysvar, datatype, defaultValue)
+	for _, alias := range structtag.Aliases(field, tag) {
+		alias = envName(alias)
+		ret, ok = Lookup(alias)
+		if ok {
+			Warn(structtag.Deprecation(field, sysvar(alias), sysvar(name)))
+			return
+		}
+	}
+	return
+}
+
+// readsFile returns true if the value of the field can be read from the file named by <VAR>_FILE
+func readsFile(field *reflect.StructField) bool {
+	return Files || field.Tag.Get(structtag.TagSecret) == "true"
+}
+
+// lookupFile returns the trimmed contents of the file named by the
+// environment variable name with FileSuffix
+func lookupFile(field *reflect.StructField, name string) (ret string, ok bool, err error) {
+	if !readsFile(field) {
+		return
+	}
+	file, ok := Lookup(name + FileSuffix)
+	if !ok {
+		return
+	}
+	byt, err := ioutil.ReadFile(file)
+	if err != nil {
+		err = fmt.Errorf("field %v, %v: %w", field.Name, sysvar(name+FileSuffix), err)
+		return
+	}
+	ret = strings.TrimSpace(string(byt))
+	return
+}
+
+func getNewValue(field *reflect.StructField, value *reflect.Value, tag string, datatype string) (ret string, err error) {
+	if structtag.IsArg(field) {
+		// positional arguments are only read from the command line
+		return
+	}
+
+	defaultValue := field.Tag.Get(structtag.TagDefault)
+
+	name := envName(tag)
+
+	if !structtag.IsDeprecated(field) {
+		names := sysvar(name)
+		if readsFile(field) {
+			names += ", " + sysvar(name+FileSuffix)
+		}
+		output := fmt.Sprintf("  %v %v\n\n", names, datatype)
+		if defaultValue != "" {
+			output = fmt.Sprintf("  %v %v\n\t(default %q)\n", names, datatype, defaultValue)
+		}
+		PrintDefaultsOutput += output
 	}
-	PrintDefaultsOutput += output
 
 	// get value from environment variable
-	ret, ok := os.LookupEnv(tag)
+	ret, ok := lookupEnv(field, tag, name)
+	fileRet, fileOk, err := lookupFile(field, name)
+	if err != nil {
+		return
+	}
+	if ok && fileOk {
+		err = fmt.Errorf("field %v: %v and %v cannot be both set", field.Name, sysvar(name), sysvar(name+FileSuffix))
+		return
+	}
+	if fileOk {
+		ret = fileRet
+		return
+	}
 	if ok {
+		if AtFile || field.Tag.Get(structtag.TagAtFile) == "true" {
+			ret, err = helper.ReadAtFile(ret)
+			if err != nil {
+				err = fmt.Errorf("field %v, %v: %w", field.Name, sysvar(name), err)
+			}
+		}
 		return
 	}
 
@@ -116,8 +235,8 @@ func getNewValue(field *reflect.StructField, value *reflect.Value, tag string, d
 }
 
 func reflectInt(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
-	newValue := getNewValue(field, value, tag, "int")
-	if newValue == "" {
+	newValue, err := getNewValue(field, value, tag, "int")
+	if err != nil || newValue == "" {
 		return
 	}
 	var intNewValue int64
@@ -130,8 +249,8 @@ func reflectInt(field *reflect.StructField, value *reflect.Value, tag string) (e
 }
 
 func reflectFloat(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
-	newValue := getNewValue(field, value, tag, "float64")
-	if newValue == "" {
+	newValue, err := getNewValue(field, value, tag, "float64")
+	if err != nil || newValue == "" {
 		return
 	}
 	var floatNewValue float64
@@ -144,8 +263,8 @@ func reflectFloat(field *reflect.StructField, value *reflect.Value, tag string)
 }
 
 func reflectString(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
-	newValue := getNewValue(field, value, tag, "string")
-	if newValue == "" {
+	newValue, err := getNewValue(field, value, tag, "string")
+	if err != nil || newValue == "" {
 		return
 	}
 	value.SetString(newValue)
@@ -153,8 +272,8 @@ func reflectString(field *reflect.StructField, value *reflect.Value, tag string)
 }
 
 func reflectBool(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
-	newValue := getNewValue(field, value, tag, "bool")
-	if newValue == "" {
+	newValue, err := getNewValue(field, value, tag, "bool")
+	if err != nil || newValue == "" {
 		return
 	}
 	newValue = strings.ToLower(newValue)
@@ -163,11 +282,38 @@ func reflectBool(field *reflect.StructField, value *reflect.Value, tag string) (
 	return
 }
 
+// reflectArray sets the lists of scalars from the comma-separated items of
+// the variable, like HOSTS=a,b
 func reflectArray(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
-	// Not implemented due to serialization complexity
+	if !isList(value) {
+		if _, ok := lookupEnv(field, tag, envName(tag)); ok && !structtag.IsArg(field) {
+			err = fmt.Errorf("field %v, %v: %w", field.Name, sysvar(envName(tag)), structtag.ErrTypeNotSupported)
+		}
+		return
+	}
+	newValue, err := getNewValue(field, value, tag, "list")
+	if err != nil || newValue == "" {
+		return
+	}
+	err = helper.ParseValue(*value, newValue)
+	if err != nil {
+		err = fmt.Errorf("field %v, %v: %w", field.Name, sysvar(envName(tag)), err)
+	}
 	return
 }
 
+// isList returns true if value is a slice of scalars read from a variable
+func isList(value *reflect.Value) bool {
+	if value.Kind() != reflect.Slice {
+		return false
+	}
+	switch value.Type().Elem().Kind() {
+	case reflect.Int, reflect.Int64, reflect.Float64, reflect.String, reflect.Bool:
+		return true
+	}
+	return false
+}
+
 // PrintDefaults print the default help
 func PrintDefaults() {
 	fmt.Println("Environment variables:")
@@ -179,3 +325,76 @@ func DefaultUsage() {
 	fmt.Println("Usage")
 	PrintDefaults()
 }
+
+// Names returns the environment variables that can set the fields of
+// config, including the old names and the <VAR>_FILE variables
+func Names(config interface{}) (names []string, err error) {
+	err = walk(config, func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		name := envName(tag)
+		names = append(names, name)
+		if readsFile(field) {
+			names = append(names, name+FileSuffix)
+		}
+		for _, alias := range structtag.Aliases(field, tag) {
+			names = append(names, envName(alias))
+		}
+		return
+	})
+	return
+}
+
+// Environ returns the NAME=value pairs of the current values of config that
+// Parse reads back, deprecated fields are omitted
+func Environ(config interface{}) (env []string, err error) {
+	err = walk(config, func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		if structtag.IsDeprecated(field) || OmitDefaults && structtag.IsDefault(*value, field.Tag.Get(structtag.TagDefault)) {
+			return
+		}
+		s := helper.FormatList(*value)
+		if strings.HasPrefix(s, "@") && (AtFile || field.Tag.Get(structtag.TagAtFile) == "true") {
+			s = "@" + s
+		}
+		env = append(env, envName(tag)+"="+s)
+		return
+	})
+	return
+}
+
+// Walk calls f with the variable name of every field of config read from
+// the environment
+func Walk(config interface{}, f func(field *reflect.StructField, value *reflect.Value, name string) error) (err error) {
+	err = walk(config, func(field *reflect.StructField, value *reflect.Value, tag string) error {
+		return f(field, value, envName(tag))
+	})
+	return
+}
+
+// walk calls f for every field of config read from the environment
+func walk(config interface{}, f structtag.ReflectFunc) (err error) {
+	scalar := func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		if structtag.IsArg(field) {
+			return
+		}
+		err = f(field, value, tag)
+		return
+	}
+	list := func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		if isList(value) {
+			err = scalar(field, value, tag)
+		}
+		return
+	}
+
+	structtag.Setup()
+	structtag.Prefix = Prefix
+	structtag.Naming = Naming
+	structtag.ParseMap[reflect.Int64] = scalar
+	structtag.ParseMap[reflect.Int] = scalar
+	structtag.ParseMap[reflect.Float64] = scalar
+	structtag.ParseMap[reflect.String] = scalar
+	structtag.ParseMap[reflect.Bool] = scalar
+	structtag.ParseMap[reflect.Array] = list
+	structtag.ParseMap[reflect.Slice] = list
+	err = structtag.Parse(config, "")
+	return
+}
@@ -1,9 +1,16 @@
 package goenv
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/naming"
+	"github.com/h2oai/goconfig/structtag"
 )
 
 type testStruct struct {
@@ -126,3 +133,289 @@ func TestParse(t *testing.T) {
 		t.Fatal("s.S.S.B != \"TEST PREFIX\", s.S.S.B:", s.S.S.B)
 	}
 }
+
+func TestAlias(t *testing.T) {
+	type testAlias struct {
+		Host string `cfg:"HOST" cfgAlias:"OLD_HOST,older-host"`
+		Port int    `cfg:"PORT" cfgAlias:"OLD_PORT"`
+		Mode string `cfg:"MODE" cfgDeprecated:"use PREFIX_HOST"`
+	}
+
+	var warnings []string
+	Warn = func(msg string) {
+		warnings = append(warnings, msg)
+	}
+	defer func() {
+		Warn = helper.Warn
+	}()
+
+	Prefix = "PREFIX"
+	Setup("cfg", "cfgDefault", true)
+	PrintDefaultsOutput = ""
+
+	os.Setenv("PREFIX_OLDER_HOST", "old.example.com")
+	os.Setenv("PREFIX_PORT", "80")
+	os.Setenv("PREFIX_OLD_PORT", "8080")
+	os.Setenv("PREFIX_MODE", "fast")
+	defer func() {
+		os.Unsetenv("PREFIX_OLDER_HOST")
+		os.Unsetenv("PREFIX_PORT")
+		os.Unsetenv("PREFIX_OLD_PORT")
+		os.Unsetenv("PREFIX_MODE")
+	}()
+
+	s := &testAlias{}
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Host != "old.example.com" {
+		t.Fatal("s.Host != \"old.example.com\", s.Host:", s.Host)
+	}
+
+	if s.Port != 80 {
+		t.Fatal("s.Port != 80, s.Port:", s.Port)
+	}
+
+	if s.Mode != "fast" {
+		t.Fatal("s.Mode != \"fast\", s.Mode:", s.Mode)
+	}
+
+	if len(warnings) != 2 ||
+		warnings[0] != "$PREFIX_OLDER_HOST is deprecated, use $PREFIX_HOST" ||
+		warnings[1] != "$PREFIX_MODE is deprecated: use PREFIX_HOST" {
+		t.Fatalf("unexpected warnings %q", warnings)
+	}
+
+	if strings.Contains(PrintDefaultsOutput, "OLD") || strings.Contains(PrintDefaultsOutput, "MODE") {
+		t.Fatal("deprecated variables must not be shown in help:", PrintDefaultsOutput)
+	}
+}
+
+func TestNaming(t *testing.T) {
+	type testDB struct {
+		MaxConnCount int
+		HTTPPort     int `cfgAlias:"port"`
+	}
+	type testNaming struct {
+		LogLevel string
+		Database testDB `cfg:"db"`
+	}
+
+	Warn = func(msg string) {}
+	defer func() {
+		Warn = helper.Warn
+		Naming = nil
+		Prefix = ""
+	}()
+
+	Prefix = "myApp"
+	Naming = naming.ScreamingSnake
+	Setup("cfg", "cfgDefault")
+
+	os.Setenv("MY_APP_LOG_LEVEL", "debug")
+	os.Setenv("MY_APP_DB_MAX_CONN_COUNT", "7")
+	os.Setenv("MY_APP_DB_PORT", "8080")
+	defer func() {
+		os.Unsetenv("MY_APP_LOG_LEVEL")
+		os.Unsetenv("MY_APP_DB_MAX_CONN_COUNT")
+		os.Unsetenv("MY_APP_DB_PORT")
+	}()
+
+	s := &testNaming{}
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.LogLevel != "debug" || s.Database.MaxConnCount != 7 || s.Database.HTTPPort != 8080 {
+		t.Fatalf("unexpected values %+v", s)
+	}
+
+	names, err := Names(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "MY_APP_LOG_LEVEL MY_APP_DB_MAX_CONN_COUNT MY_APP_DB_HTTP_PORT MY_APP_DB_PORT"
+	if strings.Join(names, " ") != expected {
+		t.Fatalf("expected %q but got %q", expected, names)
+	}
+}
+
+func TestAtFile(t *testing.T) {
+	type testAtFile struct {
+		CA   string `cfg:"CA" cfgAtFile:"true"`
+		Port int    `cfg:"PORT"`
+		Name string `cfg:"NAME"`
+	}
+
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(ca, []byte("-----BEGIN CERTIFICATE-----\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := filepath.Join(dir, "port")
+	err = os.WriteFile(port, []byte("8080\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Prefix = "PREFIX"
+	Setup("cfg", "cfgDefault")
+
+	os.Setenv("PREFIX_CA", "@"+ca)
+	os.Setenv("PREFIX_PORT", "@"+port)
+	os.Setenv("PREFIX_NAME", "@@name")
+	defer func() {
+		os.Unsetenv("PREFIX_CA")
+		os.Unsetenv("PREFIX_PORT")
+		os.Unsetenv("PREFIX_NAME")
+	}()
+
+	s := &testAtFile{}
+	err = Parse(&testAtFile{})
+	if err == nil {
+		t.Fatal("Error expected, PORT does not accept @path")
+	}
+
+	AtFile = true
+	defer func() {
+		AtFile = false
+	}()
+	err = Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.CA != "-----BEGIN CERTIFICATE-----" {
+		t.Fatalf("unexpected s.CA %q", s.CA)
+	}
+
+	if s.Port != 8080 {
+		t.Fatal("s.Port != 8080, s.Port:", s.Port)
+	}
+
+	if s.Name != "@name" {
+		t.Fatal("s.Name != \"@name\", s.Name:", s.Name)
+	}
+
+	os.Setenv("PREFIX_CA", "@"+filepath.Join(dir, "missing.pem"))
+	err = Parse(s)
+	if err == nil || !strings.Contains(err.Error(), "field CA, $PREFIX_CA") {
+		t.Fatal("expected error naming the field but got", err)
+	}
+}
+
+func TestFileSecret(t *testing.T) {
+	type testSecret struct {
+		Password string `cfg:"PASSWORD" cfgSecret:"true"`
+		User     string `cfg:"USER"`
+	}
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "db")
+	err := os.WriteFile(secret, []byte("  s3cr3t\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Prefix = "PREFIX"
+	Setup("cfg", "cfgDefault")
+	PrintDefaultsOutput = ""
+
+	os.Setenv("PREFIX_PASSWORD_FILE", secret)
+	os.Setenv("PREFIX_USER_FILE", secret)
+	defer func() {
+		os.Unsetenv("PREFIX_PASSWORD_FILE")
+		os.Unsetenv("PREFIX_USER_FILE")
+		os.Unsetenv("PREFIX_PASSWORD")
+	}()
+
+	s := &testSecret{}
+	err = Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Password != "s3cr3t" {
+		t.Fatalf("s.Password != \"s3cr3t\", s.Password: %q", s.Password)
+	}
+
+	if s.User != "" {
+		t.Fatal("s.User is not a secret, s.User:", s.User)
+	}
+
+	if !strings.Contains(PrintDefaultsOutput, "$PREFIX_PASSWORD, $PREFIX_PASSWORD_FILE string") {
+		t.Fatal("the _FILE variable must be shown in help:", PrintDefaultsOutput)
+	}
+
+	Files = true
+	defer func() {
+		Files = false
+	}()
+	s = &testSecret{}
+	err = Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.User != "s3cr3t" {
+		t.Fatal("s.User != \"s3cr3t\", s.User:", s.User)
+	}
+
+	os.Setenv("PREFIX_PASSWORD", "other")
+	err = Parse(&testSecret{})
+	if err == nil {
+		t.Fatal("Error expected when both variables are set")
+	}
+
+	os.Unsetenv("PREFIX_PASSWORD")
+	os.Setenv("PREFIX_PASSWORD_FILE", filepath.Join(dir, "missing"))
+	err = Parse(&testSecret{})
+	if err == nil || !strings.Contains(err.Error(), "field Password, $PREFIX_PASSWORD_FILE") {
+		t.Fatal("expected error naming the field but got", err)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	type testSlice struct {
+		Hosts []string `cfg:"HOSTS" cfgDefault:"a,b"`
+		Ports []int    `cfg:"PORTS"`
+		Subs  []testSub
+	}
+
+	Prefix = "PREFIX"
+	Setup("cfg", "cfgDefault")
+
+	os.Setenv("PREFIX_PORTS", "80, 443")
+	defer os.Unsetenv("PREFIX_PORTS")
+
+	s := &testSlice{}
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Hosts) != 2 || s.Hosts[1] != "b" {
+		t.Fatal("s.Hosts != [a b], s.Hosts:", s.Hosts)
+	}
+	if len(s.Ports) != 2 || s.Ports[1] != 443 {
+		t.Fatal("s.Ports != [80 443], s.Ports:", s.Ports)
+	}
+
+	env, err := Environ(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(env, " ") != "PREFIX_HOSTS=a,b PREFIX_PORTS=80,443" {
+		t.Fatal("unexpected environment", env)
+	}
+
+	os.Setenv("PREFIX_SUBS", "x")
+	defer os.Unsetenv("PREFIX_SUBS")
+	Setup("cfg", "cfgDefault")
+	err = Parse(&testSlice{})
+	if !errors.Is(err, structtag.ErrTypeNotSupported) {
+		t.Fatal("expected ErrTypeNotSupported but got", err)
+	}
+}
@@ -0,0 +1,215 @@
+package goflags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/h2oai/goconfig/structtag"
+)
+
+// argRest is the index of the field receiving the remaining arguments
+const argRest = -1
+
+type argumentMeta struct {
+	Index int
+	Name  string
+	Field reflect.StructField
+	Value reflect.Value
+}
+
+var (
+	arguments []argumentMeta
+
+	// ErrTooManyArguments error when the command line has more positional arguments than fields
+	ErrTooManyArguments = errors.New("too many arguments")
+)
+
+// positional wraps f to bind the fields tagged with cfgArg to positional
+// arguments instead of flags, the parent commands define neither the
+// arguments nor the flags already defined
+func positional(f structtag.ReflectFunc) structtag.ReflectFunc {
+	return func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		if inheriting && (structtag.IsArg(field) || flag.Lookup(flagName(tag)) != nil) {
+			return
+		}
+		if !structtag.IsArg(field) {
+			err = f(field, value, tag)
+			return
+		}
+		err = defineArgument(field, value, tag)
+		return
+	}
+}
+
+func defineArgument(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+	meta := argumentMeta{
+		Index: argRest,
+		Name:  flagName(tag),
+		Field: *field,
+		Value: *value,
+	}
+	index := field.Tag.Get(structtag.TagArg)
+	if index != "rest" {
+		meta.Index, err = strconv.Atoi(index)
+		if err != nil || meta.Index < 0 {
+			err = fmt.Errorf("argument %v: invalid index %q", meta.Name, index)
+			return
+		}
+	} else if value.Kind() != reflect.Slice {
+		err = fmt.Errorf("argument %v: %w, rest must be a slice", meta.Name, structtag.ErrTypeNotSupported)
+		return
+	}
+	for _, a := range arguments {
+		if a.Index == meta.Index {
+			err = fmt.Errorf("argument %v: index %q already used by %v", meta.Name, index, a.Name)
+			return
+		}
+	}
+	arguments = append(arguments, meta)
+	sort.SliceStable(arguments, func(i, j int) bool {
+		if arguments[i].Index == argRest {
+			return false
+		}
+		if arguments[j].Index == argRest {
+			return true
+		}
+		return arguments[i].Index < arguments[j].Index
+	})
+	return
+}
+
+// bindArguments sets the fields tagged with cfgArg from args
+func bindArguments(args []string) (err error) {
+	if len(arguments) == 0 {
+		return
+	}
+
+	max := 0
+	for _, a := range arguments {
+		if a.Index == argRest {
+			max = len(args)
+			break
+		}
+		max = a.Index + 1
+	}
+	if len(args) > max {
+		err = fmt.Errorf("%w: %q", ErrTooManyArguments, args[max:])
+		return
+	}
+
+	for _, a := range arguments {
+		var values []string
+		switch {
+		case a.Index == argRest:
+			if first := restIndex(); first < len(args) {
+				values = args[first:]
+			}
+		case a.Index < len(args):
+			values = args[a.Index : a.Index+1]
+		}
+
+		if values == nil {
+			if a.Field.Tag.Get("cfgRequired") == "true" {
+				err = fmt.Errorf("argument %v is required", a.Name)
+				return
+			}
+			if !a.Value.IsZero() {
+				continue
+			}
+			defaultValue := a.Field.Tag.Get(structtag.TagDefault)
+			if defaultValue == "" {
+				continue
+			}
+			values = []string{defaultValue}
+			if a.Index == argRest {
+				values = strings.Split(defaultValue, ",")
+			}
+		}
+
+		err = setArgument(a.Value, values)
+		if err != nil {
+			err = fmt.Errorf("argument %v: %w", a.Name, err)
+			return
+		}
+	}
+	return
+}
+
+// restIndex returns the index of the first argument received by the rest field
+func restIndex() (index int) {
+	for _, a := range arguments {
+		if a.Index != argRest && a.Index >= index {
+			index = a.Index + 1
+		}
+	}
+	return
+}
+
+func setArgument(value reflect.Value, values []string) (err error) {
+	if value.Kind() != reflect.Slice {
+		err = setValue(value, values[0])
+		return
+	}
+	slice := reflect.MakeSlice(value.Type(), len(values), len(values))
+	for i, v := range values {
+		err = setValue(slice.Index(i), v)
+		if err != nil {
+			return
+		}
+	}
+	value.Set(slice)
+	return
+}
+
+func setValue(value reflect.Value, s string) (err error) {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Int, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 10, 64)
+		value.SetInt(i)
+	case reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, 64)
+		value.SetFloat(f)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		value.SetBool(b)
+	default:
+		err = structtag.ErrTypeNotSupported
+	}
+	return
+}
+
+// printArguments print the help of the positional arguments
+func printArguments() {
+	if len(arguments) == 0 {
+		return
+	}
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Arguments:")
+	for _, a := range arguments {
+		name := a.Name
+		if a.Index == argRest {
+			name += "..."
+		}
+		if a.Field.Tag.Get("cfgRequired") == "true" {
+			name = "<" + name + ">"
+		} else {
+			name = "[" + name + "]"
+		}
+
+		line := fmt.Sprintf("  %v %v\n    \t%v", name, a.Field.Type, a.Field.Tag.Get(structtag.TagHelper))
+		if defaultValue := a.Field.Tag.Get(structtag.TagDefault); defaultValue != "" {
+			line += fmt.Sprintf(" (default %q)", defaultValue)
+		}
+		fmt.Fprintln(out, line)
+	}
+}
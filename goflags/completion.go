@@ -0,0 +1,186 @@
+package goflags
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/h2oai/goconfig/structtag"
+)
+
+var (
+	// ErrUnsupportedShell error when a completion script is requested for an unknown shell
+	ErrUnsupportedShell = errors.New("unsupported shell")
+
+	// TagPath marks a field that holds a file path, completed with file names
+	TagPath = "cfgPath"
+
+	// ProgramName used in completion scripts, defaults to the base name of os.Args[0]
+	ProgramName string
+)
+
+// Completion writes a completion script for shell (bash, zsh or fish) with the flags generated from config
+func Completion(config interface{}, shell string, w io.Writer) (err error) {
+	var write func(w io.Writer, prog string, flags []Flag) error
+	switch shell {
+	case "bash":
+		write = bashCompletion
+	case "zsh":
+		write = zshCompletion
+	case "fish":
+		write = fishCompletion
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnsupportedShell, shell)
+		return
+	}
+
+	all, err := Flags(config)
+	if err != nil {
+		return
+	}
+	var flags []Flag
+	for _, f := range all {
+		if f.Deprecated == "" {
+			flags = append(flags, f)
+		}
+	}
+
+	prog := ProgramName
+	if prog == "" {
+		prog = filepath.Base(os.Args[0])
+	}
+	err = write(w, prog, flags)
+	return
+}
+
+// oneOf returns the values accepted by the flag, if restricted
+func oneOf(f Flag) (values []string) {
+	tag := f.Field.Tag.Get(structtag.TagOneOf)
+	if tag == "" {
+		return
+	}
+	for _, v := range strings.Split(tag, ",") {
+		values = append(values, strings.TrimSpace(v))
+	}
+	return
+}
+
+// takesValue returns false for the flags that are set without a value
+func takesValue(f Flag) bool {
+	return f.Kind != reflect.Bool && f.Field.Tag.Get(TagCount) != "true"
+}
+
+func isPath(f Flag) bool {
+	return f.Field.Tag.Get(TagPath) == "true"
+}
+
+var nonIdent = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func bashCompletion(w io.Writer, prog string, flags []Flag) (err error) {
+	var names, cases []string
+	for _, f := range flags {
+		names = append(names, dashed(f.Name))
+		patterns := "-" + f.Name + "|--" + f.Name
+		if f.Short != "" {
+			names = append(names, "-"+f.Short)
+			patterns += "|-" + f.Short
+		}
+		if !takesValue(f) {
+			continue
+		}
+		action := `return`
+		if values := oneOf(f); values != nil {
+			action = fmt.Sprintf(`COMPREPLY=($(compgen -W %q -- "$cur")); return`, strings.Join(values, " "))
+		} else if isPath(f) {
+			action = `COMPREPLY=($(compgen -f -- "$cur")); return`
+		}
+		cases = append(cases, fmt.Sprintf("\t%s)\n\t\t%s\n\t\t;;", patterns, action))
+	}
+
+	fn := "_" + nonIdent.ReplaceAllString(prog, "_") + "_completion"
+	_, err = fmt.Fprintf(w, `# bash completion for %[1]s
+%[2]s() {
+	local cur prev
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	if [[ "$cur" == "=" ]]; then
+		cur=""
+	elif [[ "$prev" == "=" ]]; then
+		prev="${COMP_WORDS[COMP_CWORD-2]}"
+	fi
+
+	case "$prev" in
+%[3]s
+	esac
+
+	COMPREPLY=($(compgen -W %[4]q -- "$cur"))
+}
+complete -F %[2]s %[1]s
+`, prog, fn, strings.Join(cases, "\n"), strings.Join(names, " "))
+	return
+}
+
+var zshEscaper = strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`)
+
+func zshCompletion(w io.Writer, prog string, flags []Flag) (err error) {
+	var specs []string
+	for _, f := range flags {
+		usage := zshEscaper.Replace(f.Usage)
+		if !takesValue(f) {
+			specs = append(specs, fmt.Sprintf("'%s[%s]'", dashed(f.Name), usage))
+			if f.Short != "" {
+				specs = append(specs, fmt.Sprintf("'-%s[%s]'", f.Short, usage))
+			}
+			continue
+		}
+		action := " "
+		if values := oneOf(f); values != nil {
+			action = "(" + zshEscaper.Replace(strings.Join(values, " ")) + ")"
+		} else if isPath(f) {
+			action = "_files"
+		}
+		specs = append(specs, fmt.Sprintf("'%s=[%s]:%s:%s'", dashed(f.Name), usage, f.Name, action))
+		if f.Short != "" {
+			specs = append(specs, fmt.Sprintf("'-%s+[%s]:%s:%s'", f.Short, usage, f.Name, action))
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "#compdef %s\n\n_arguments \\\n\t%s\n", prog, strings.Join(specs, " \\\n\t"))
+	return
+}
+
+var fishEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func fishCompletion(w io.Writer, prog string, flags []Flag) (err error) {
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c %s -o %s", prog, f.Name)
+		if DoubleDash {
+			line = fmt.Sprintf("complete -c %s -l %s", prog, f.Name)
+		}
+		if f.Short != "" {
+			line += " -s " + f.Short
+		}
+		if f.Usage != "" {
+			line += fmt.Sprintf(" -d '%s'", fishEscaper.Replace(f.Usage))
+		}
+		if takesValue(f) {
+			if values := oneOf(f); values != nil {
+				line += fmt.Sprintf(" -x -a '%s'", fishEscaper.Replace(strings.Join(values, " ")))
+			} else if isPath(f) {
+				line += " -r -F"
+			} else {
+				line += " -x"
+			}
+		}
+		_, err = fmt.Fprintln(w, line)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
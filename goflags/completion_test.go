@@ -0,0 +1,66 @@
+package goflags
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type testCompletion struct {
+	Format  string `cfg:"format" cfgOneOf:"json,text" cfgHelper:"output format"`
+	Config  string `cfg:"config" cfgPath:"true"`
+	Verbose bool   `cfg:"verbose"`
+	Mongo   struct {
+		Host string `cfg:"host"`
+	} `cfg:"mongo"`
+}
+
+func TestCompletion(t *testing.T) {
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	ProgramName = "app"
+
+	tests := map[string][]string{
+		"bash": {
+			`-format|--format)`,
+			`compgen -W "json text"`,
+			`-config|--config)`,
+			`compgen -f`,
+			`compgen -W "-format -config -verbose -mongo_host"`,
+			`complete -F _app_completion app`,
+		},
+		"zsh": {
+			`#compdef app`,
+			`'-format=[output format]:format:(json text)'`,
+			`'-config=[]:config:_files'`,
+			`'-verbose[]'`,
+			`'-mongo_host=[]:mongo_host: '`,
+		},
+		"fish": {
+			`complete -c app -o format -d 'output format' -x -a 'json text'`,
+			`complete -c app -o config -r -F`,
+			"complete -c app -o verbose\n",
+			`complete -c app -o mongo_host -x`,
+		},
+	}
+
+	for shell, expected := range tests {
+		buf := &bytes.Buffer{}
+		err := Completion(&testCompletion{}, shell, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range expected {
+			if !strings.Contains(buf.String(), e) {
+				t.Fatalf("%s completion does not contain %q:\n%s", shell, e, buf.String())
+			}
+		}
+		if strings.Contains(buf.String(), "-verbose|") {
+			t.Fatal("boolean flags must not take a value")
+		}
+	}
+
+	err := Completion(&testCompletion{}, "tcsh", &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("Error expected")
+	}
+}
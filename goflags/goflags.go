@@ -1,36 +1,82 @@
 package goflags
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
 	"flag"
 
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/naming"
 	"github.com/h2oai/goconfig/structtag"
 )
 
 type parameterMeta struct {
+	Kind    reflect.Kind
+	Value   interface{}
+	Tag     string
+	Aliases []string
+}
+
+// Flag describes a command line flag generated from a struct field
+type Flag struct {
+	Name  string
 	Kind  reflect.Kind
-	Value interface{}
-	Tag   string
+	Short string
+	Usage string
+	Field reflect.StructField
+
+	// Deprecated holds the deprecation warning of the flag, hidden from the help when set
+	Deprecated string
 }
 
 var (
 	parametersMetaMap map[*reflect.Value]parameterMeta
 	visitedMap        map[string]*flag.Flag
+	deprecatedMap     map[string]string
+	shortMap          map[string]string
 	disableFags       bool
 
+	// inheriting is set while the flags of the parent commands are defined
+	inheriting bool
+
 	// Preserve disable default values and get only visited parameters thus preserving the values passed in the structure, default false
 	Preserve bool
 
 	// Prefix is a string that would be placed at the beginning of the generated tags.
 	Prefix string
 
+	// Separator is placed between the names of nested fields, like -mongodb_host, -mongodb.host or -mongodb-host
+	Separator = "_"
+
+	// DoubleDash shows long flags with two dashes in the help, like --mongodb_host, one dash is always accepted
+	DoubleDash bool
+
+	// Naming maps the field names and the cfg tags to the flag names, like naming.Kebab, the names are lowercased when nil
+	Naming naming.Mapper
+
+	// TagShort sets a single letter alias of the flag, like -p
+	TagShort = "cfgShort"
+
+	// TagCount turns an int flag into a counter incremented each time it is set, like -v -v or -vv
+	TagCount = "cfgCount"
+
+	// AtFile reads every flag value starting with @ from the file named after it, like -tls_ca=@/etc/ca.pem, fields can also enable it with the cfgAtFile tag
+	AtFile bool
+
+	// NegatePrefix is placed before the name of boolean flags that default to true to define the flag that disables them, like -no-color
+	NegatePrefix = "no-"
+
 	//Usage is a function to show the help, can be replaced by your own version.
 	Usage func()
+
+	// Warn is the function called to report the use of deprecated flags, can be replaced by your own version.
+	Warn = helper.Warn
 )
 
 // Setup maps and variables
@@ -38,18 +84,48 @@ func Setup(tag, tagDefault, TagHelper string) {
 	Usage = DefaultUsage
 	parametersMetaMap = make(map[*reflect.Value]parameterMeta)
 	visitedMap = make(map[string]*flag.Flag)
+	deprecatedMap = make(map[string]string)
+	shortMap = make(map[string]string)
+	arguments = nil
 
-	structtag.Setup()
-	structtag.Prefix = Prefix
+	setupStructtag()
 	SetTag(tag)
 	SetTagDefault(tagDefault)
 	SetTagHelper(TagHelper)
 
-	structtag.ParseMap[reflect.Int64] = reflectInt
-	structtag.ParseMap[reflect.Int] = reflectInt
-	structtag.ParseMap[reflect.Float64] = reflectFloat
-	structtag.ParseMap[reflect.String] = reflectString
-	structtag.ParseMap[reflect.Bool] = reflectBool
+	structtag.ParseMap[reflect.Int64] = positional(reflectInt)
+	structtag.ParseMap[reflect.Int] = positional(reflectInt)
+	structtag.ParseMap[reflect.Float64] = positional(reflectFloat)
+	structtag.ParseMap[reflect.String] = positional(reflectString)
+	structtag.ParseMap[reflect.Bool] = positional(reflectBool)
+	structtag.ParseMap[reflect.Array] = positional(structtag.ReflectArray)
+	structtag.ParseMap[reflect.Slice] = positional(structtag.ReflectArray)
+}
+
+// setupStructtag prepares structtag to generate the flag names
+func setupStructtag() {
+	structtag.Setup()
+	structtag.Prefix = Prefix
+	if Separator != "" {
+		structtag.TagSeparator = Separator
+	}
+	structtag.Naming = Naming
+}
+
+// flagName returns the command line name of the field identified by tag
+func flagName(tag string) string {
+	if Naming != nil {
+		return tag
+	}
+	return strings.ToLower(tag)
+}
+
+// dashed returns the flag name as shown to the user
+func dashed(name string) string {
+	if DoubleDash && len(name) > 1 {
+		return "--" + name
+	}
+	return "-" + name
 }
 
 // SetTag set a new tag
@@ -73,18 +149,50 @@ func Parse(config interface{}) (err error) {
 		return
 	}
 
+	err = ParseArgs(config, os.Args[1:])
+	if err != nil {
+		return
+	}
+
+	disableFags = true
+	return
+}
+
+// ParseArgs defines the flags of config on flag.CommandLine and parses them
+// from args, the arguments after the flags are returned by flag.Args. The
+// flags of parents, like the global flags of a subcommand, are accepted too
+// unless config defines the same names.
+func ParseArgs(config interface{}, args []string, parents ...interface{}) (err error) {
 	flag.Usage = Usage
+	flag.CommandLine.Usage = Usage
 	err = structtag.Parse(config, "")
 	if err != nil {
 		return
 	}
+	err = inherit(parents)
+	if err != nil {
+		return
+	}
 
-	flag.Parse()
+	// the command line error handling applies like with flag.Parse, the
+	// errors of ContinueOnError are returned
+	args = expandShort(args)
+	if i, name := firstUnknown(args); i >= 0 {
+		err = flag.CommandLine.Parse(args[:i])
+		if err == nil {
+			err = unknownFlag(name)
+		}
+	} else {
+		err = flag.CommandLine.Parse(args)
+	}
+	if err != nil {
+		return
+	}
 
 	flag.Visit(loadVisit)
 
 	for k, v := range parametersMetaMap {
-		if _, ok := visitedMap[v.Tag]; !ok && Preserve {
+		if !visited(v) && Preserve {
 			continue
 		}
 
@@ -104,10 +212,32 @@ func Parse(config interface{}) (err error) {
 		}
 	}
 
-	disableFags = true
+	err = bindArguments(flag.Args())
 	return
 }
 
+// inherit defines the flags of the parents, skipping the positional
+// arguments and the names already defined
+func inherit(parents []interface{}) (err error) {
+	inheriting = true
+	defer func() {
+		inheriting = false
+	}()
+	for _, parent := range parents {
+		err = structtag.Parse(parent, "")
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// defined returns true if name is already a flag while the parent flags
+// are defined
+func defined(name string) bool {
+	return inheriting && flag.Lookup(name) != nil
+}
+
 // Reset maps caling setup function
 func Reset() {
 	disableFags = false
@@ -118,8 +248,283 @@ func Reset() {
 	Setup(structtag.Tag, structtag.TagDefault, structtag.TagHelper)
 }
 
+// Flags returns the flags that Parse defines for config, in declaration
+// order, without defining them on the command line
+func Flags(config interface{}) (flags []Flag, err error) {
+	setupStructtag()
+
+	collect := func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		if structtag.IsArg(field) {
+			return
+		}
+		flags = append(flags, Flag{
+			Name:  flagName(tag),
+			Kind:  field.Type.Kind(),
+			Short: field.Tag.Get(TagShort),
+			Usage: field.Tag.Get(structtag.TagHelper),
+			Field: *field,
+		})
+		if structtag.IsDeprecated(field) {
+			flags[len(flags)-1].Deprecated = structtag.Deprecation(field, dashed(flagName(tag)), "")
+		} else if field.Type.Kind() == reflect.Bool && NegatePrefix != "" && isTrue(field.Tag.Get(structtag.TagDefault)) {
+			flags = append(flags, Flag{
+				Name:  NegatePrefix + flagName(tag),
+				Kind:  reflect.Bool,
+				Usage: "disables " + dashed(flagName(tag)),
+				Field: *field,
+			})
+		}
+		return
+	}
+	structtag.ParseMap[reflect.Int64] = collect
+	structtag.ParseMap[reflect.Int] = collect
+	structtag.ParseMap[reflect.Float64] = collect
+	structtag.ParseMap[reflect.String] = collect
+	structtag.ParseMap[reflect.Bool] = collect
+
+	err = structtag.Parse(config, "")
+	return
+}
+
+// Args returns the command line arguments holding the current values of
+// config that Parse reads back, positional arguments follow a "--"
+func Args(config interface{}) (args []string, err error) {
+	setupStructtag()
+
+	var positionals []argumentMeta
+	collect := func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		if structtag.IsArg(field) {
+			meta := argumentMeta{Index: argRest, Field: *field, Value: *value}
+			if index := field.Tag.Get(structtag.TagArg); index != "rest" {
+				meta.Index, err = strconv.Atoi(index)
+			}
+			positionals = append(positionals, meta)
+			return
+		}
+		if structtag.IsDeprecated(field) || value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+			return
+		}
+		s := helper.FormatValue(*value)
+		if strings.HasPrefix(s, "@") && (AtFile || field.Tag.Get(structtag.TagAtFile) == "true") {
+			s = "@" + s
+		}
+		args = append(args, dashed(flagName(tag))+"="+s)
+		return
+	}
+	structtag.ParseMap[reflect.Int64] = collect
+	structtag.ParseMap[reflect.Int] = collect
+	structtag.ParseMap[reflect.Float64] = collect
+	structtag.ParseMap[reflect.String] = collect
+	structtag.ParseMap[reflect.Bool] = collect
+	structtag.ParseMap[reflect.Array] = collect
+	structtag.ParseMap[reflect.Slice] = collect
+
+	err = structtag.Parse(config, "")
+	if err != nil || len(positionals) == 0 {
+		return
+	}
+
+	sort.SliceStable(positionals, func(i, j int) bool {
+		if positionals[i].Index == argRest {
+			return false
+		}
+		return positionals[j].Index == argRest || positionals[i].Index < positionals[j].Index
+	})
+	args = append(args, "--")
+	for _, a := range positionals {
+		if a.Index != argRest {
+			args = append(args, helper.FormatValue(a.Value))
+			continue
+		}
+		for i := 0; i < a.Value.Len(); i++ {
+			args = append(args, helper.FormatValue(a.Value.Index(i)))
+		}
+	}
+	return
+}
+
 func loadVisit(f *flag.Flag) {
 	visitedMap[f.Name] = f
+	if msg, ok := deprecatedMap[f.Name]; ok {
+		Warn(msg)
+	}
+}
+
+// visited returns true if the flag or one of its aliases was set on the command line
+func visited(meta parameterMeta) bool {
+	if _, ok := visitedMap[meta.Tag]; ok {
+		return true
+	}
+	for _, alias := range meta.Aliases {
+		if _, ok := visitedMap[alias]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// defineAliases defines the short name and the old names of the flag
+// sharing its value and registers the deprecation warnings
+func defineAliases(field *reflect.StructField, tag string, meta *parameterMeta) (err error) {
+	if structtag.IsDeprecated(field) {
+		deprecatedMap[meta.Tag] = structtag.Deprecation(field, dashed(meta.Tag), "")
+	}
+	f := flag.Lookup(meta.Tag)
+	if AtFile || field.Tag.Get(structtag.TagAtFile) == "true" {
+		f.Value = &fileValue{Value: f.Value, field: field.Name}
+	}
+	if short := field.Tag.Get(TagShort); short != "" && !defined(short) {
+		if len([]rune(short)) != 1 {
+			err = fmt.Errorf("%v: short flag %q must be a single character", dashed(meta.Tag), short)
+			return
+		}
+		if long, ok := shortMap[short]; ok {
+			err = fmt.Errorf("%v: short flag -%v already used by %v", dashed(meta.Tag), short, dashed(long))
+			return
+		}
+		flag.Var(f.Value, short, f.Usage)
+		shortMap[short] = meta.Tag
+		meta.Aliases = append(meta.Aliases, short)
+	}
+	for _, alias := range structtag.Aliases(field, tag) {
+		name := flagName(alias)
+		if defined(name) {
+			continue
+		}
+		flag.Var(f.Value, name, f.Usage)
+		deprecatedMap[name] = structtag.Deprecation(field, dashed(name), dashed(meta.Tag))
+		meta.Aliases = append(meta.Aliases, name)
+	}
+	return
+}
+
+// expandShort splits combined short flags, -vq becomes -v -q and -p8080
+// becomes -p=8080, names defined as long flags are kept as they are
+func expandShort(args []string) (ret []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			ret = append(ret, args[i:]...)
+			return
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		hasValue := strings.Contains(name, "=")
+		name = strings.SplitN(name, "=", 2)[0]
+		if f := flag.Lookup(name); f != nil || hasValue || arg[1] == '-' {
+			ret = append(ret, arg)
+			if f != nil && !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+				// the next argument is the value of this flag
+				i++
+				ret = append(ret, args[i])
+			}
+			continue
+		}
+
+		expanded, next := expandCombined(name)
+		if expanded == nil {
+			ret = append(ret, arg)
+			continue
+		}
+		ret = append(ret, expanded...)
+		if next && i+1 < len(args) {
+			i++
+			ret = append(ret, args[i])
+		}
+	}
+	return
+}
+
+// firstUnknown returns the index and name of the first flag of args that is
+// not defined, index is -1 when all flags are known
+func firstUnknown(args []string) (index int, name string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+		name = strings.TrimPrefix(arg[1:], "-")
+		hasValue := strings.Contains(name, "=")
+		name = strings.SplitN(name, "=", 2)[0]
+		f := flag.Lookup(name)
+		if f == nil {
+			if name == "h" || name == "help" || name == "" || name[0] == '-' || name[0] == '=' {
+				// left to the flag package
+				break
+			}
+			index = i
+			return
+		}
+		if !hasValue && !isBoolFlag(f) {
+			i++
+		}
+	}
+	index, name = -1, ""
+	return
+}
+
+// unknownFlag reports the flag name like the flag package does, suggesting
+// the closest defined flag, and returns the error
+func unknownFlag(name string) (err error) {
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, ok := deprecatedMap[f.Name]; !ok {
+			names = append(names, f.Name)
+		}
+	})
+	msg := fmt.Sprintf("flag provided but not defined: -%v", name)
+	if suggestion := helper.Suggest(name, names); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %v?", dashed(suggestion))
+	}
+	err = errors.New(msg)
+	fmt.Fprintln(flag.CommandLine.Output(), msg)
+	flag.CommandLine.Usage()
+
+	switch flag.CommandLine.ErrorHandling() {
+	case flag.ExitOnError:
+		os.Exit(2)
+	case flag.PanicOnError:
+		panic(msg)
+	}
+	return
+}
+
+// expandCombined splits a group of one letter flags, short names or long
+// names of one letter, next is true when the last flag takes its value from
+// the following argument
+func expandCombined(group string) (expanded []string, next bool) {
+	runes := []rune(group)
+	for i, r := range runes {
+		short := string(r)
+		f := flag.Lookup(short)
+		if f == nil {
+			expanded = nil
+			return
+		}
+		if isBoolFlag(f) {
+			expanded = append(expanded, "-"+short)
+			continue
+		}
+		if i == len(runes)-1 {
+			expanded = append(expanded, "-"+short)
+			next = true
+			return
+		}
+		expanded = append(expanded, "-"+short+"="+string(runes[i+1:]))
+		return
+	}
+	return
+}
+
+// isTrue returns true if the default value of a boolean flag is true
+func isTrue(defaultValue string) bool {
+	defaultValue = strings.ToLower(defaultValue)
+	return defaultValue == "true" || defaultValue == "t" || defaultValue == "1"
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
 }
 
 func reflectInt(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
@@ -139,11 +544,17 @@ func reflectInt(field *reflect.StructField, value *reflect.Value, tag string) (e
 
 	meta := parameterMeta{}
 	meta.Value = &aux
-	meta.Tag = strings.ToLower(tag)
+	meta.Tag = flagName(tag)
 	meta.Kind = reflect.Int
-	parametersMetaMap[value] = meta
 
-	flag.IntVar(&aux, meta.Tag, defaltValueInt, usage)
+	if field.Tag.Get(TagCount) == "true" {
+		aux = defaltValueInt
+		flag.Var((*countValue)(&aux), meta.Tag, usage)
+	} else {
+		flag.IntVar(&aux, meta.Tag, defaltValueInt, usage)
+	}
+	err = defineAliases(field, tag, &meta)
+	parametersMetaMap[value] = meta
 
 	return
 }
@@ -165,11 +576,12 @@ func reflectFloat(field *reflect.StructField, value *reflect.Value, tag string)
 
 	meta := parameterMeta{}
 	meta.Value = &aux
-	meta.Tag = strings.ToLower(tag)
+	meta.Tag = flagName(tag)
 	meta.Kind = reflect.Float64
-	parametersMetaMap[value] = meta
 
 	flag.Float64Var(&aux, meta.Tag, defaltValueFloat, usage)
+	err = defineAliases(field, tag, &meta)
+	parametersMetaMap[value] = meta
 
 	return
 }
@@ -182,36 +594,87 @@ func reflectString(field *reflect.StructField, value *reflect.Value, tag string)
 
 	meta := parameterMeta{}
 	meta.Value = &aux
-	meta.Tag = strings.ToLower(tag)
+	meta.Tag = flagName(tag)
 	meta.Kind = reflect.String
-	parametersMetaMap[value] = meta
 
 	flag.StringVar(&aux, meta.Tag, defaltValue, usage)
+	err = defineAliases(field, tag, &meta)
+	parametersMetaMap[value] = meta
 
 	return
 }
 
 func reflectBool(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
 	var aux bool
-	defaltTag := field.Tag.Get(structtag.TagDefault)
-	defaltTag = strings.ToLower(defaltTag)
-	newValue := defaltTag == "true" || defaltTag == "t" || defaltTag == "1"
+	newValue := isTrue(field.Tag.Get(structtag.TagDefault))
 	usage := field.Tag.Get(structtag.TagHelper)
 
 	meta := parameterMeta{}
 	meta.Value = &aux
-	meta.Tag = strings.ToLower(tag)
+	meta.Tag = flagName(tag)
 	meta.Kind = reflect.Bool
-	parametersMetaMap[value] = meta
 
 	flag.BoolVar(&aux, meta.Tag, newValue, usage)
+	if newValue && NegatePrefix != "" && !defined(NegatePrefix+meta.Tag) {
+		negated := NegatePrefix + meta.Tag
+		flag.Var(&negatedValue{value: &aux}, negated, "disables "+dashed(meta.Tag))
+		meta.Aliases = append(meta.Aliases, negated)
+		if structtag.IsDeprecated(field) {
+			deprecatedMap[negated] = structtag.Deprecation(field, dashed(negated), "")
+		}
+	}
+	err = defineAliases(field, tag, &meta)
+	parametersMetaMap[value] = meta
 
 	return
 }
 
-// PrintDefaults print the default help
+// PrintDefaults print the default help, deprecated flags and aliases are omitted
 func PrintDefaults() {
-	flag.PrintDefaults()
+	shorts := make(map[string]string)
+	for short, long := range shortMap {
+		shorts[long] = short
+	}
+
+	out := flag.CommandLine.Output()
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, ok := deprecatedMap[f.Name]; ok {
+			return
+		}
+		if _, ok := shortMap[f.Name]; ok {
+			return
+		}
+
+		line := "  " + dashed(f.Name)
+		if short, ok := shorts[f.Name]; ok {
+			line = "  -" + short + ", " + dashed(f.Name)
+		}
+		name, usage := flag.UnquoteUsage(&flag.Flag{Usage: f.Usage, Value: baseValue(f.Value)})
+		if name != "" {
+			line += " " + name
+		}
+		if len(line) <= 4 {
+			line += "\t"
+		} else {
+			line += "\n    \t"
+		}
+		line += strings.Replace(usage, "\n", "\n    \t", -1)
+		if !isZeroValue(f) {
+			if reflect.TypeOf(baseValue(f.Value)).Elem().Kind() == reflect.String {
+				line += fmt.Sprintf(" (default %q)", f.DefValue)
+			} else {
+				line += fmt.Sprintf(" (default %v)", f.DefValue)
+			}
+		}
+		fmt.Fprintln(out, line)
+	})
+	printArguments()
+}
+
+// isZeroValue returns true if the default value of the flag is the zero value of its type
+func isZeroValue(f *flag.Flag) bool {
+	zero := reflect.New(reflect.TypeOf(baseValue(f.Value)).Elem()).Interface().(flag.Value)
+	return f.DefValue == zero.String()
 }
 
 // DefaultUsage is assigned for Usage function by default
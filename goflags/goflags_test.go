@@ -1,9 +1,17 @@
 package goflags
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/naming"
 )
 
 type testStruct struct {
@@ -127,3 +135,434 @@ func TestPreserve(t *testing.T) {
 		t.Fatal("s.S.S.A != 99999, s.S.S.A:", s.S.S.A)
 	}
 }
+
+func TestAlias(t *testing.T) {
+	type testAlias struct {
+		Host string `cfg:"host" cfgAlias:"old_host,older-host"`
+		Port int    `cfg:"port" cfgAlias:"old_port"`
+		Mode string `cfg:"mode" cfgDeprecated:"use -host"`
+	}
+
+	var warnings []string
+	Warn = func(msg string) {
+		warnings = append(warnings, msg)
+	}
+	defer func() {
+		Warn = helper.Warn
+	}()
+
+	os.Args = []string{
+		"program",
+		"-older-host=old.example.com",
+		"-port=80",
+		"-mode=fast",
+	}
+
+	s := &testAlias{}
+
+	Reset()
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	Preserve = true
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Host != "old.example.com" {
+		t.Fatal("s.Host != \"old.example.com\", s.Host:", s.Host)
+	}
+
+	if s.Port != 80 {
+		t.Fatal("s.Port != 80, s.Port:", s.Port)
+	}
+
+	if s.Mode != "fast" {
+		t.Fatal("s.Mode != \"fast\", s.Mode:", s.Mode)
+	}
+
+	if len(warnings) != 2 ||
+		warnings[0] != "-mode is deprecated: use -host" ||
+		warnings[1] != "-older-host is deprecated, use -host" {
+		t.Fatalf("unexpected warnings %q", warnings)
+	}
+
+	buf := &bytes.Buffer{}
+	flag.CommandLine.SetOutput(buf)
+	PrintDefaults()
+	if !strings.Contains(buf.String(), "-host") ||
+		strings.Contains(buf.String(), "old") ||
+		strings.Contains(buf.String(), "mode") {
+		t.Fatal("deprecated flags must not be shown in help:", buf.String())
+	}
+}
+
+func TestNaming(t *testing.T) {
+	type testDB struct {
+		MaxConnCount int
+		HTTPPort     int `cfgAlias:"port"`
+	}
+	type testNaming struct {
+		LogLevel string
+		Database testDB `cfg:"db"`
+	}
+
+	Warn = func(msg string) {}
+	defer func() {
+		Warn = helper.Warn
+		Naming = nil
+		Separator = "_"
+	}()
+
+	os.Args = []string{
+		"program",
+		"--log-level=debug",
+		"-db-max-conn-count=7",
+		"-db-port", "8080",
+	}
+
+	s := &testNaming{}
+
+	Reset()
+	Naming = naming.Kebab
+	Separator = "-"
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	Preserve = true
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.LogLevel != "debug" || s.Database.MaxConnCount != 7 || s.Database.HTTPPort != 8080 {
+		t.Fatalf("unexpected values %+v", s)
+	}
+
+	buf := &bytes.Buffer{}
+	flag.CommandLine.SetOutput(buf)
+	PrintDefaults()
+	if !strings.Contains(buf.String(), "-db-http-port") {
+		t.Fatal("expected kebab-case flags in the help:", buf.String())
+	}
+}
+
+func TestShort(t *testing.T) {
+	type testShortSub struct {
+		Host string `cfg:"host"`
+	}
+	type testShort struct {
+		Port    int          `cfg:"port" cfgShort:"p" cfgHelper:"http port"`
+		Verbose bool         `cfg:"verbose" cfgShort:"v"`
+		Quiet   bool         `cfg:"quiet" cfgShort:"q"`
+		Name    string       `cfg:"name" cfgShort:"n"`
+		Mongo   testShortSub `cfg:"mongodb"`
+	}
+
+	os.Args = []string{
+		"program",
+		"-vq",
+		"-p8080",
+		"--mongodb.host=example.com",
+		"-n", "-vq",
+	}
+
+	s := &testShort{}
+
+	Reset()
+	Separator = "."
+	DoubleDash = true
+	defer func() {
+		Separator = "_"
+		DoubleDash = false
+	}()
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	Preserve = true
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Verbose || !s.Quiet {
+		t.Fatal("s.Verbose and s.Quiet must be true:", s.Verbose, s.Quiet)
+	}
+
+	if s.Port != 8080 {
+		t.Fatal("s.Port != 8080, s.Port:", s.Port)
+	}
+
+	if s.Name != "-vq" {
+		t.Fatal("s.Name != \"-vq\", s.Name:", s.Name)
+	}
+
+	if s.Mongo.Host != "example.com" {
+		t.Fatal("s.Mongo.Host != \"example.com\", s.Mongo.Host:", s.Mongo.Host)
+	}
+
+	buf := &bytes.Buffer{}
+	flag.CommandLine.SetOutput(buf)
+	PrintDefaults()
+	expected := "  -p, --port int\n    \thttp port\n"
+	if !strings.Contains(buf.String(), expected) ||
+		!strings.Contains(buf.String(), "  --mongodb.host string\n") {
+		t.Fatalf("expected %q in help:\n%s", expected, buf.String())
+	}
+
+	type testShortInvalid struct {
+		Port int `cfg:"port" cfgShort:"po"`
+	}
+	Reset()
+	err = Parse(&testShortInvalid{})
+	if err == nil {
+		t.Fatal("Error expected")
+	}
+}
+
+func TestParseError(t *testing.T) {
+	type testParseError struct {
+		Port int `cfg:"port" cfgShort:"p"`
+	}
+
+	os.Args = []string{"program", "-p", "http"}
+
+	s := &testParseError{}
+	Reset()
+	flag.CommandLine.SetOutput(&bytes.Buffer{})
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	Usage = func() {}
+	err := Parse(s)
+	if err == nil || !strings.Contains(err.Error(), `invalid value "http" for flag -p`) {
+		t.Fatal("expected the invalid value error but got", err)
+	}
+}
+
+func TestArguments(t *testing.T) {
+	type testArgs struct {
+		Verbose bool     `cfg:"verbose"`
+		Source  string   `cfg:"source" cfgArg:"0" cfgRequired:"true" cfgHelper:"source file"`
+		Count   int      `cfg:"count" cfgArg:"1" cfgDefault:"3"`
+		Files   []string `cfg:"files" cfgArg:"rest"`
+	}
+
+	os.Args = []string{"program", "-verbose", "src.txt", "5", "a", "b"}
+
+	s := &testArgs{}
+	Reset()
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Verbose || s.Source != "src.txt" || s.Count != 5 {
+		t.Fatalf("unexpected arguments %+v", s)
+	}
+
+	if len(s.Files) != 2 || s.Files[0] != "a" || s.Files[1] != "b" {
+		t.Fatal("s.Files != [a b], s.Files:", s.Files)
+	}
+
+	buf := &bytes.Buffer{}
+	flag.CommandLine.SetOutput(buf)
+	PrintDefaults()
+	expected := "Arguments:\n  <source> string\n    \tsource file\n  [count] int\n    \t (default \"3\")\n  [files...] []string\n"
+	if !strings.Contains(buf.String(), expected) || strings.Contains(buf.String(), "-source") {
+		t.Fatalf("expected %q in help:\n%s", expected, buf.String())
+	}
+
+	os.Args = []string{"program", "src.txt"}
+	s = &testArgs{}
+	Reset()
+	err = Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Count != 3 || s.Files != nil {
+		t.Fatalf("unexpected arguments %+v", s)
+	}
+
+	os.Args = []string{"program"}
+	Reset()
+	err = Parse(&testArgs{})
+	if err == nil {
+		t.Fatal("Error expected")
+	}
+
+	os.Args = []string{"program", "src.txt", "NaN"}
+	Reset()
+	err = Parse(&testArgs{})
+	if err == nil {
+		t.Fatal("Error expected")
+	}
+
+	type testTooMany struct {
+		Source string `cfg:"source" cfgArg:"0"`
+	}
+	os.Args = []string{"program", "a", "b"}
+	Reset()
+	err = Parse(&testTooMany{})
+	if !errors.Is(err, ErrTooManyArguments) {
+		t.Fatal("ErrTooManyArguments expected but got", err)
+	}
+}
+
+func TestNegateAndCount(t *testing.T) {
+	type testNegate struct {
+		Color     bool `cfg:"color" cfgDefault:"true"`
+		Cache     bool `cfg:"cache" cfgDefault:"true"`
+		Verbosity int  `cfg:"verbosity" cfgShort:"v" cfgCount:"true"`
+		Retries   int  `cfg:"retries" cfgCount:"true" cfgDefault:"1"`
+	}
+
+	os.Args = []string{"program", "-no-color", "-v", "-vv", "--retries", "--retries"}
+
+	s := &testNegate{}
+	Reset()
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	Preserve = false
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Color {
+		t.Fatal("s.Color != false")
+	}
+
+	if !s.Cache {
+		t.Fatal("s.Cache != true")
+	}
+
+	if s.Verbosity != 3 {
+		t.Fatal("s.Verbosity != 3, s.Verbosity:", s.Verbosity)
+	}
+
+	if s.Retries != 3 {
+		t.Fatal("s.Retries != 3, s.Retries:", s.Retries)
+	}
+
+	os.Args = []string{"program", "-verbosity=5"}
+	s = &testNegate{}
+	Reset()
+	err = Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Verbosity != 5 || !s.Color {
+		t.Fatalf("unexpected flags %+v", s)
+	}
+
+	buf := &bytes.Buffer{}
+	flag.CommandLine.SetOutput(buf)
+	PrintDefaults()
+	expected := "  -no-cache\n    \tdisables -cache\n"
+	if !strings.Contains(buf.String(), expected) || !strings.Contains(buf.String(), "  -v, -verbosity\n") {
+		t.Fatalf("expected %q in help:\n%s", expected, buf.String())
+	}
+}
+
+func TestCountLongOnly(t *testing.T) {
+	type testCount struct {
+		V     int  `cfg:"v" cfgCount:"true"`
+		Quiet bool `cfg:"q"`
+		Debug int  `cfg:"debug" cfgCount:"true"`
+	}
+
+	os.Args = []string{"program", "-vvv", "-qv", "-debug", "--debug"}
+
+	s := &testCount{}
+	Reset()
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	Preserve = false
+	err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.V != 4 || !s.Quiet || s.Debug != 2 {
+		t.Fatalf("unexpected flags %+v", s)
+	}
+}
+
+func TestAtFile(t *testing.T) {
+	type testAtFile struct {
+		CA   string `cfg:"tls_ca" cfgAtFile:"true"`
+		Port int    `cfg:"port" cfgAtFile:"true" cfgDefault:"80"`
+		Name string `cfg:"name"`
+	}
+
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(ca, []byte("-----BEGIN CERTIFICATE-----\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Args = []string{"program", "-tls_ca=@" + ca, "-name=@name"}
+
+	s := &testAtFile{}
+	Reset()
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	err = Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.CA != "-----BEGIN CERTIFICATE-----" {
+		t.Fatalf("unexpected s.CA %q", s.CA)
+	}
+
+	if s.Name != "@name" {
+		t.Fatal("s.Name != \"@name\", s.Name:", s.Name)
+	}
+
+	buf := &bytes.Buffer{}
+	flag.CommandLine.SetOutput(buf)
+	PrintDefaults()
+	if !strings.Contains(buf.String(), "  -port int\n    \t (default 80)\n") {
+		t.Fatal("unexpected help:", buf.String())
+	}
+
+	err = flag.Set("tls_ca", "@"+filepath.Join(dir, "missing.pem"))
+	if err == nil || !strings.Contains(err.Error(), "field CA") {
+		t.Fatal("expected error naming the field but got", err)
+	}
+
+	os.Args = []string{"program", "-tls_ca=@" + filepath.Join(dir, "missing.pem")}
+	s = &testAtFile{}
+	Reset()
+	flag.CommandLine.SetOutput(&bytes.Buffer{})
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	Usage = func() {}
+	err = Parse(s)
+	if err == nil || !strings.Contains(err.Error(), "missing.pem") {
+		t.Fatal("expected the missing file error from Parse but got", err)
+	}
+}
+
+func TestUnknownFlag(t *testing.T) {
+	type testUnknown struct {
+		Port  int    `cfg:"port"`
+		Host  string `cfg:"host"`
+		Debug bool   `cfg:"debug"`
+	}
+
+	os.Args = []string{"program", "-host", "example.com", "-prot", "8080", "-debug"}
+
+	s := &testUnknown{}
+	Reset()
+	var out bytes.Buffer
+	flag.CommandLine.SetOutput(&out)
+	Setup("cfg", "cfgDefault", "cfgHelper")
+	Usage = func() {}
+	Preserve = true
+	err := Parse(s)
+	if err == nil || err.Error() != "flag provided but not defined: -prot, did you mean -port?" {
+		t.Fatal("expected the unknown flag error with a suggestion but got", err)
+	}
+
+	if s.Debug {
+		t.Fatal("flags after the unknown flag must not be parsed")
+	}
+	expected := "flag provided but not defined: -prot, did you mean -port?\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, out.String())
+	}
+}
@@ -0,0 +1,94 @@
+package goflags
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/h2oai/goconfig/helper"
+)
+
+// negatedValue sets a boolean flag to false, used by the -no-<flag> flags
+type negatedValue struct {
+	value *bool
+}
+
+func (n *negatedValue) Set(s string) (err error) {
+	var b bool
+	b, err = strconv.ParseBool(s)
+	if err != nil {
+		return
+	}
+	*n.value = !b
+	return
+}
+
+func (n *negatedValue) String() string {
+	return "false"
+}
+
+func (n *negatedValue) IsBoolFlag() bool {
+	return true
+}
+
+// countValue increments the int flag each time it is set without a value,
+// -v -v or -vv sets 2 and -v=5 sets 5
+type countValue int
+
+func (c *countValue) Set(s string) (err error) {
+	if s == "true" {
+		*c++
+		return
+	}
+	var i int
+	i, err = strconv.Atoi(s)
+	if err != nil {
+		return
+	}
+	*c = countValue(i)
+	return
+}
+
+func (c *countValue) String() string {
+	return strconv.Itoa(int(*c))
+}
+
+func (c *countValue) IsBoolFlag() bool {
+	return true
+}
+
+// fileValue reads the values starting with @ from the file named after it
+type fileValue struct {
+	flag.Value
+	field string
+}
+
+func (v *fileValue) Set(s string) (err error) {
+	s, err = helper.ReadAtFile(s)
+	if err != nil {
+		err = fmt.Errorf("field %v: %w", v.field, err)
+		return
+	}
+	err = v.Value.Set(s)
+	return
+}
+
+func (v *fileValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+func (v *fileValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// baseValue returns the value wrapped by fileValue
+func baseValue(v flag.Value) flag.Value {
+	if fv, ok := v.(*fileValue); ok {
+		return fv.Value
+	}
+	return v
+}
@@ -8,21 +8,33 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/helper"
 	"github.com/fatih/structs"
 	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
 	"github.com/hashicorp/hcl/hcl/printer"
 	jsonParser "github.com/hashicorp/hcl/json/parser"
 )
 
 func init() {
-	f := goconfig.Fileformat{
+	err := goconfig.RegisterFormat(goconfig.Fileformat{
+		Name:        "hcl",
 		Extension:   ".hcl",
+		Comments:    true,
 		Load:        LoadHCL,
+		Decode:      DecodeHCL,
+		Encode:      EncodeHCL,
+		KeyOf:       keyOf,
 		PrepareHelp: PrepareHelp,
+	})
+	if err != nil {
+		panic(err)
 	}
-	goconfig.Formats = append(goconfig.Formats, f)
 }
 
 // LoadHCL config file
@@ -35,18 +47,136 @@ func LoadHCL(config interface{}) (err error) {
 		}
 		return
 	}
-	byt, err := ioutil.ReadFile(configFile) // nolint
-	if err != nil && err != io.EOF {
+	file, err := os.Open(configFile) // nolint
+	if err != nil {
+		return
+	}
+	defer helper.Closer(file)
+
+	err = DecodeHCL(file, config)
+	return
+}
+
+// DecodeHCL reads the HCL document from r into config
+func DecodeHCL(r io.Reader, config interface{}) (err error) {
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	byt, err = preprocess(byt, config)
+	if err != nil {
 		return
 	}
 	err = hcl.Unmarshal(byt, config)
 	return
 }
 
-// PrepareHelp return help string for this file format.
-func PrepareHelp(config interface{}) (help string, err error) {
-	structs.DefaultTagName = "hcl"
-	m := structs.Map(config)
+// preprocess applies goconfig.StrictFile, the included files and the keys
+// listed on cfgAlias to the document byt, a renamed document is returned as
+// JSON which is also valid HCL
+func preprocess(byt []byte, config interface{}) (ret []byte, err error) {
+	ret = byt
+	var m map[string]interface{}
+	if hcl.Unmarshal(byt, &m) != nil {
+		// let the struct decoding report the error
+		return
+	}
+	keys := goconfig.FileKeyOf(keyOf)
+	if goconfig.StrictFile {
+		err = goconfig.UnknownKeysError(goconfig.UnknownKeys(config, m, keys), func(key string) int {
+			return keyLine(byt, key)
+		})
+		if err != nil {
+			return
+		}
+	}
+	err = goconfig.Include(config, m)
+	if err != nil {
+		return
+	}
+	renamed := goconfig.RenameAliases(config, m, keys)
+	if goconfig.RenameFileKeys(config, m, keyOf) || renamed {
+		ret, err = json.Marshal(m)
+	}
+	return
+}
+
+// keyLine returns the line of the document byt defining the nested key, like
+// mongodb[0].host, or 0 when it is not found
+func keyLine(byt []byte, key string) (line int) {
+	file, err := hcl.ParseBytes(byt)
+	if err != nil {
+		return
+	}
+	list, _ := file.Node.(*ast.ObjectList)
+	for _, part := range strings.Split(key, ".") {
+		if list == nil {
+			return 0
+		}
+		index := 0
+		if i := strings.Index(part, "["); i > 0 && strings.HasSuffix(part, "]") {
+			index, _ = strconv.Atoi(part[i+1 : len(part)-1])
+			part = part[:i]
+		}
+		type elem struct {
+			line int
+			list *ast.ObjectList
+		}
+		var elems []elem
+		for _, item := range list.Filter(part).Items {
+			if len(item.Keys) > 0 {
+				elems = append(elems, elem{item.Pos().Line, &ast.ObjectList{Items: []*ast.ObjectItem{item}}})
+				continue
+			}
+			if l, ok := item.Val.(*ast.ListType); ok {
+				for _, node := range l.List {
+					elems = append(elems, elem{node.Pos().Line, objectList(node)})
+				}
+				continue
+			}
+			elems = append(elems, elem{item.Val.Pos().Line, objectList(item.Val)})
+		}
+		if index >= len(elems) {
+			return 0
+		}
+		line, list = elems[index].line, elems[index].list
+	}
+	return
+}
+
+// objectList returns the items of the object node, or nil
+func objectList(node ast.Node) *ast.ObjectList {
+	if o, ok := node.(*ast.ObjectType); ok {
+		return o.List
+	}
+	return nil
+}
+
+func keyOf(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("hcl"), ",")[0]
+	if key == "" {
+		key = field.Name
+	}
+	return key
+}
+
+// EncodeHCL writes config as an HCL document to w
+func EncodeHCL(w io.Writer, config interface{}) (err error) {
+	var m map[string]interface{}
+	if goconfig.SaveOmitDefaults {
+		m = goconfig.Values(config, goconfig.FileKeyOf(keyOf))
+	} else if goconfig.RenamedFileKeys() {
+		m = goconfig.Map(config, goconfig.FileKeyOf(keyOf))
+	} else {
+		structs.DefaultTagName = "hcl"
+		m = structs.Map(config)
+	}
+	err = encode(w, m)
+	return
+}
+
+// encode prints m as HCL through its JSON representation
+func encode(w io.Writer, m map[string]interface{}) (err error) {
 	byt, err := json.Marshal(m)
 	if err != nil {
 		return
@@ -55,8 +185,21 @@ func PrepareHelp(config interface{}) (help string, err error) {
 	if err != nil {
 		return
 	}
+	err = printer.Fprint(w, ast)
+	return
+}
+
+// PrepareHelp return help string for this file format.
+func PrepareHelp(config interface{}) (help string, err error) {
+	var m map[string]interface{}
+	if goconfig.RenamedFileKeys() {
+		m = goconfig.Map(config, goconfig.FileKeyOf(keyOf))
+	} else {
+		structs.DefaultTagName = "hcl"
+		m = structs.Map(config)
+	}
 	buff := &bytes.Buffer{}
-	err = printer.Fprint(buff, ast)
+	err = encode(buff, m)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,50 @@
+package hcl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/h2oai/goconfig"
+)
+
+type server struct {
+	Host string `hcl:"host"`
+}
+
+type strictConfig struct {
+	Name    string   `hcl:"name"`
+	Servers []server `hcl:"servers"`
+	MongoDB server   `hcl:"mongodb"`
+}
+
+func TestStrict(t *testing.T) {
+	goconfig.StrictFile = true
+	defer func() {
+		goconfig.StrictFile = false
+	}()
+
+	doc := `# hsot = "commented"
+name = "hsot = in a string"
+
+mongodb {
+  hsot = "a"
+}
+
+servers {
+  host = "a"
+}
+
+servers {
+  hsot = "b"
+}
+`
+	err := DecodeHCL(strings.NewReader(doc), &strictConfig{})
+	if !errors.Is(err, goconfig.ErrUnknownKey) {
+		t.Fatal("expected ErrUnknownKey but got", err)
+	}
+	expected := "unknown config file key: mongodb[0].hsot (line 5), servers[1].hsot (line 13)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q but got %q", expected, err.Error())
+	}
+}
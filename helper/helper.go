@@ -1,8 +1,14 @@
 package helper
 
 import (
+	"fmt"
 	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
 
+	"github.com/h2oai/goconfig/structtag"
 	"github.com/nuveo/log"
 )
 
@@ -13,3 +19,147 @@ func Closer(f io.Closer) {
 		log.Errorln(err)
 	}
 }
+
+// Warn logs a warning message
+func Warn(msg string) {
+	log.Warningln(msg)
+}
+
+// ReadAtFile returns the contents of the file named after the @ when value
+// starts with @, like @/etc/ca.pem, trailing new lines are removed. A value
+// starting with @@ is returned with a single @.
+func ReadAtFile(value string) (ret string, err error) {
+	ret = value
+	if !strings.HasPrefix(value, "@") {
+		return
+	}
+	if strings.HasPrefix(value, "@@") {
+		ret = value[1:]
+		return
+	}
+	byt, err := ioutil.ReadFile(value[1:])
+	if err != nil {
+		return
+	}
+	ret = strings.TrimRight(string(byt), "\r\n")
+	return
+}
+
+// Suggest returns the candidate most similar to name, ignoring case, or an
+// empty string when no candidate is close enough to be a likely typo
+func Suggest(name string, candidates []string) (suggestion string) {
+	best := len(name)/3 + 1
+	for _, c := range candidates {
+		d := distance(strings.ToLower(name), strings.ToLower(c))
+		if d < best {
+			best = d
+			suggestion = c
+		}
+	}
+	return
+}
+
+// distance returns the number of insertions, deletions, substitutions and
+// transpositions of adjacent characters needed to turn a into b
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(values ...int) (m int) {
+	m = values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return
+}
+
+// FormatValue returns the string that parses back into the scalar value,
+// durations are written in nanoseconds like the other int64 fields
+func FormatValue(value reflect.Value) (ret string) {
+	switch value.Kind() {
+	case reflect.String:
+		ret = value.String()
+	case reflect.Int, reflect.Int64:
+		ret = strconv.FormatInt(value.Int(), 10)
+	case reflect.Float64:
+		ret = strconv.FormatFloat(value.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		ret = strconv.FormatBool(value.Bool())
+	}
+	return
+}
+
+// FormatList returns the value of a scalar like FormatValue, or the
+// comma-separated items of a list
+func FormatList(value reflect.Value) string {
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return FormatValue(value)
+	}
+	items := make([]string, value.Len())
+	for i := range items {
+		items[i] = FormatValue(value.Index(i))
+	}
+	return strings.Join(items, ",")
+}
+
+// ParseValue parses s into the scalar value, or the comma-separated items
+// of s into the list value
+func ParseValue(value reflect.Value, s string) (err error) {
+	switch value.Kind() {
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		list := reflect.MakeSlice(value.Type(), len(items), len(items))
+		for i, item := range items {
+			err = ParseValue(list.Index(i), item)
+			if err != nil {
+				return
+			}
+		}
+		value.Set(list)
+	case reflect.Int, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 10, 64)
+		value.SetInt(i)
+	case reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, 64)
+		value.SetFloat(f)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		value.SetBool(b)
+	case reflect.String:
+		value.SetString(s)
+	default:
+		err = fmt.Errorf("%w: %v", structtag.ErrTypeNotSupported, value.Type())
+	}
+	return
+}
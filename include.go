@@ -0,0 +1,207 @@
+package goconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	// IncludeKey is the config file key listing other files to load, like
+	// include: ["db.yaml", "conf.d/*.yaml"], with paths relative to the
+	// including file. They are loaded before the including file, so its own
+	// keys take precedence. Disabled when empty.
+	IncludeKey = "include"
+
+	// DropInDir is a directory, relative to Path, whose files are merged in
+	// lexical order after the config file, like conf.d. Files without a
+	// registered format are ignored. Disabled when empty.
+	DropInDir string
+
+	// ErrIncludeCycle is returned when a config file includes itself
+	ErrIncludeCycle = errors.New("include cycle")
+
+	// ErrIncludeNotSupported is returned by LoadReader and LoadBytes for the
+	// documents including other files, they have no directory to find them
+	ErrIncludeNotSupported = errors.New("include not supported when reading a document")
+
+	// loading is the stack of config files being loaded, the paths of
+	// loadingFS while LoadFS reads it and empty for the readers
+	loading []string
+
+	// loadingFS is the file system read by LoadFS
+	loadingFS fs.FS
+
+	// loadedFiles lists every config file loaded, watched by ParseAndWatch
+	loadedFiles []string
+)
+
+// Include is used by the file formats to support IncludeKey. It loads the
+// files listed under IncludeKey in the decoded document m, a string with
+// comma-separated paths or a list of strings, that can be glob patterns.
+// The documents decoded outside of Parse, LoadReader and LoadFS are ignored.
+func Include(config interface{}, m interface{}) (err error) {
+	if IncludeKey == "" || len(loading) == 0 {
+		return
+	}
+	value := reflect.ValueOf(m)
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Map {
+		return
+	}
+	key, ok := lookupKey(value, IncludeKey)
+	if !ok {
+		return
+	}
+
+	var patterns []string
+	list := reflect.ValueOf(value.MapIndex(key).Interface())
+	if list.Kind() == reflect.Slice {
+		for i := 0; i < list.Len(); i++ {
+			patterns = append(patterns, fmt.Sprint(list.Index(i).Interface()))
+		}
+	} else {
+		patterns = strings.Split(fmt.Sprint(list.Interface()), ",")
+	}
+
+	current := loading[len(loading)-1]
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if current == "" {
+			err = fmt.Errorf("%w: %v", ErrIncludeNotSupported, pattern)
+			return
+		}
+		var files []string
+		files, err = includedFiles(current, pattern)
+		if err != nil {
+			return
+		}
+		for _, file := range files {
+			if loadingFS != nil {
+				err = loadFS(config, file)
+			} else {
+				err = loadFile(config, file)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// includedFiles returns the files matching pattern, relative to the
+// directory of the including file current, in loadingFS while LoadFS reads it
+func includedFiles(current, pattern string) (files []string, err error) {
+	if loadingFS != nil {
+		pattern = path.Join(path.Dir(current), pattern)
+		files, err = fs.Glob(loadingFS, pattern)
+	} else {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(current), pattern)
+		}
+		files, err = filepath.Glob(pattern)
+	}
+	if err == nil && len(files) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		err = fmt.Errorf("include %v: %w", pattern, os.ErrNotExist)
+	}
+	return
+}
+
+// loadFile loads file with the format found by fileFormat
+func loadFile(config interface{}, file string) (err error) {
+	format, err := fileFormat(file)
+	if err != nil {
+		err = fmt.Errorf("%v: %w", file, err)
+		return
+	}
+	err = loadFormat(format, config, file)
+	return
+}
+
+// loadFormat loads file with format, setting Path and File while the format reads it
+func loadFormat(format Fileformat, config interface{}, file string) (err error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return
+	}
+	for i, f := range loading {
+		if f == abs {
+			err = fmt.Errorf("%w: %v", ErrIncludeCycle, strings.Join(append(loading[i:], abs), " -> "))
+			return
+		}
+	}
+
+	prevPath, prevFile := Path, File
+	Path, File = filepath.Dir(file), filepath.Base(file)
+	loading = append(loading, abs)
+	loadedFiles = append(loadedFiles, file)
+	defer func() {
+		Path, File = prevPath, prevFile
+		loading = loading[:len(loading)-1]
+	}()
+
+	err = format.Load(config)
+	return
+}
+
+// loadDropIns loads the files of DropInDir in lexical order
+func loadDropIns(config interface{}) (err error) {
+	if DropInDir == "" {
+		return
+	}
+	dir := filepath.Join(Path, DropInDir)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		format, e := findFileFormat(filepath.Ext(entry.Name()))
+		if e != nil {
+			continue
+		}
+		err = loadFormat(format, config, filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// watchFiles adds the loaded config files and DropInDir to the watcher
+func watchFiles(w *fsnotify.Watcher) (err error) {
+	for _, file := range loadedFiles {
+		if _, e := os.Stat(file); e != nil {
+			continue
+		}
+		err = w.Add(file)
+		if err != nil {
+			return
+		}
+	}
+	if DropInDir != "" {
+		if _, e := os.Stat(filepath.Join(Path, DropInDir)); e == nil {
+			err = w.Add(filepath.Join(Path, DropInDir))
+		}
+	}
+	return
+}
@@ -1,21 +1,45 @@
 package ini
 
 import (
-	"encoding/json"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/structtag"
 	ini "gopkg.in/ini.v1"
 )
 
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+
+	// listEscaper escapes the list items like ini.Key.Strings reads them
+	listEscaper = strings.NewReplacer(`\\`, `\\\\`, ",", `\,`)
+)
+
 func init() {
-	f := goconfig.Fileformat{
+	err := goconfig.RegisterFormat(goconfig.Fileformat{
+		Name:        "ini",
 		Extension:   ".ini",
+		Comments:    true,
 		Load:        LoadINI,
+		Decode:      DecodeINI,
+		Encode:      EncodeINI,
+		KeyOf:       keyOf,
 		PrepareHelp: PrepareHelp,
+	})
+	if err != nil {
+		panic(err)
 	}
-	goconfig.Formats = append(goconfig.Formats, f)
 }
 
 // LoadINI config file
@@ -29,17 +53,436 @@ func LoadINI(config interface{}) (err error) {
 		return
 	}
 
-	err = ini.MapTo(config, file)
+	defer helper.Closer(file)
+
+	err = DecodeINI(file, config)
+	return
+}
+
+// DecodeINI reads the INI document from r into config. The keys of the
+// default section set the top level fields and each section sets the
+// nested struct named after it, like [mongodb] or [mongodb.tls]. Values are
+// read like ini.MapTo reads them and lists are comma-separated.
+func DecodeINI(r io.Reader, config interface{}) (err error) {
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	cfg, err := ini.Load(byt)
+	if err != nil {
+		return
+	}
+	if goconfig.StrictFile {
+		err = goconfig.UnknownKeysError(goconfig.UnknownKeys(config, sections(cfg), keyOf), func(key string) int {
+			return keyLine(byt, key)
+		})
+		if err != nil {
+			return
+		}
+	}
+	err = goconfig.Include(config, sections(cfg))
+	if err != nil {
+		return
+	}
+
+	values := make(map[string]*ini.Key)
+	for _, sec := range cfg.Sections() {
+		for _, k := range sec.Keys() {
+			values[strings.ToLower(join(sectionName(sec), k.Name()))] = k
+		}
+	}
+	err = walk(config, "", func(field reflect.StructField, value reflect.Value, section, key string) (err error) {
+		if !isValue(field.Type) {
+			return
+		}
+		name := join(section, key)
+		k, ok := values[strings.ToLower(name)]
+		if ok && structtag.IsDeprecated(&field) {
+			goconfig.Warn(structtag.Deprecation(&field, fmt.Sprintf("config file key %q", name), ""))
+		}
+		for _, alias := range strings.Split(field.Tag.Get(structtag.TagAlias), ",") {
+			alias = strings.TrimSpace(alias)
+			if ok || alias == "" {
+				continue
+			}
+			k, ok = values[strings.ToLower(join(section, alias))]
+			if ok {
+				goconfig.Warn(structtag.Deprecation(&field,
+					fmt.Sprintf("config file key %q", join(section, alias)),
+					fmt.Sprintf("%q", name)))
+			}
+		}
+		if !ok {
+			return
+		}
+		err = setValue(value, k)
+		if err != nil {
+			err = fmt.Errorf("%v: %w", name, err)
+		}
+		return
+	})
 	return
 }
 
+// setValue parses the value of k into value like ini.MapTo, durations
+// are read like 5s and times like 2006-01-02T15:04:05Z
+func setValue(value reflect.Value, k *ini.Key) (err error) {
+	if value.Kind() == reflect.Ptr {
+		ptr := reflect.New(value.Type().Elem())
+		err = setValue(ptr.Elem(), k)
+		if err == nil {
+			value.Set(ptr)
+		}
+		return
+	}
+
+	var v interface{}
+	switch value.Type() {
+	case durationType:
+		v, err = k.Duration()
+		if err != nil {
+			v, err = k.Int64()
+		}
+	case timeType:
+		v, err = k.Time()
+	}
+	if v != nil || err != nil {
+		if err == nil {
+			value.Set(reflect.ValueOf(v).Convert(value.Type()))
+		}
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(k.String())
+	case reflect.Bool:
+		var b bool
+		b, err = k.Bool()
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = k.Int64()
+		if err == nil && value.OverflowInt(i) {
+			err = fmt.Errorf("%v overflows %v", i, value.Type())
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = k.Uint64()
+		if err == nil && value.OverflowUint(u) {
+			err = fmt.Errorf("%v overflows %v", u, value.Type())
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = k.Float64()
+		value.SetFloat(f)
+	case reflect.Slice:
+		switch value.Type().Elem().Kind() {
+		case reflect.String:
+			v = k.Strings(",")
+		case reflect.Int64:
+			if value.Type().Elem() == durationType {
+				v, err = durations(k)
+			} else {
+				v, err = k.StrictInt64s(",")
+			}
+		case reflect.Int:
+			v, err = k.StrictInts(",")
+		case reflect.Uint:
+			v, err = k.StrictUints(",")
+		case reflect.Uint64:
+			v, err = k.StrictUint64s(",")
+		case reflect.Float64:
+			v, err = k.StrictFloat64s(",")
+		case reflect.Bool:
+			v, err = k.StrictBools(",")
+		default:
+			v, err = k.StrictTimes(",")
+		}
+		if err != nil {
+			return
+		}
+		items := reflect.ValueOf(v)
+		list := reflect.MakeSlice(value.Type(), items.Len(), items.Len())
+		for i := 0; i < items.Len(); i++ {
+			list.Index(i).Set(items.Index(i).Convert(list.Type().Elem()))
+		}
+		value.Set(list)
+	default:
+		err = fmt.Errorf("%w: %v", structtag.ErrTypeNotSupported, value.Type())
+	}
+	return
+}
+
+// durations returns the comma-separated durations of k
+func durations(k *ini.Key) (ret []time.Duration, err error) {
+	for _, item := range k.Strings(",") {
+		var d time.Duration
+		d, err = time.ParseDuration(item)
+		if err != nil {
+			return
+		}
+		ret = append(ret, d)
+	}
+	return
+}
+
+// formatValue returns the string that setValue parses back into value,
+// the commas and backslashes of the list items are escaped
+func formatValue(value reflect.Value) string {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	switch {
+	case value.Type() == durationType:
+		return time.Duration(value.Int()).String()
+	case value.Type() == timeType:
+		return value.Interface().(time.Time).Format(time.RFC3339)
+	}
+	switch value.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 32)
+	case reflect.Slice, reflect.Array:
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = formatValue(value.Index(i))
+			if value.Index(i).Kind() == reflect.String {
+				items[i] = listEscaper.Replace(items[i])
+			}
+		}
+		return strings.Join(items, ",")
+	}
+	return helper.FormatValue(value)
+}
+
+// isValue returns true if the fields of type t are read from a key, the
+// types read by ini.MapTo
+func isValue(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice {
+		switch t.Elem().Kind() {
+		case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64, reflect.String, reflect.Bool:
+			return true
+		}
+		return t.Elem() == timeType
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return t == timeType
+}
+
+// sections returns the keys of the default section and a nested map for
+// each other section
+func sections(cfg *ini.File) (m map[string]interface{}) {
+	m = make(map[string]interface{})
+	for _, sec := range cfg.Sections() {
+		keys := m
+		if name := sectionName(sec); name != "" {
+			for _, part := range strings.Split(name, ".") {
+				sub, ok := keys[part].(map[string]interface{})
+				if !ok {
+					sub = make(map[string]interface{})
+					keys[part] = sub
+				}
+				keys = sub
+			}
+		}
+		for _, k := range sec.Keys() {
+			keys[k.Name()] = k.Value()
+		}
+	}
+	return
+}
+
+// sectionName returns the name of sec, empty for the default section
+func sectionName(sec *ini.Section) string {
+	if sec.Name() == ini.DefaultSection {
+		return ""
+	}
+	return sec.Name()
+}
+
+// keyLine returns the line of the document byt defining the key, or the
+// section, named by the dotted name, or 0 when it is not found
+func keyLine(byt []byte, name string) int {
+	section := ""
+	for i, line := range strings.Split(string(byt), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == ';' || line[0] == '#':
+			continue
+		case line[0] == '[':
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+			section = strings.TrimSpace(line[1:end])
+			if section == ini.DefaultSection {
+				section = ""
+			}
+			lower := strings.ToLower(section)
+			if lower == strings.ToLower(name) || strings.HasPrefix(lower, strings.ToLower(name)+".") {
+				return i + 1
+			}
+		default:
+			key := line
+			if sep := strings.IndexAny(line, "=:"); sep >= 0 {
+				key = line[:sep]
+			}
+			key = strings.Trim(strings.TrimSpace(key), "\"`")
+			if strings.EqualFold(join(section, key), name) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// join returns the dotted name of key in section
+func join(section, key string) string {
+	if section == "" {
+		return key
+	}
+	return section + "." + key
+}
+
+// walk calls f for the fields of the struct value with the name of their
+// section, the nested structs are walked after f is called for them and
+// embedded structs are flattened
+func walk(config interface{}, section string, f func(field reflect.StructField, value reflect.Value, section, key string) error) (err error) {
+	value := reflect.ValueOf(config)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		err = structtag.ErrNotAStruct
+		return
+	}
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldValue := value.Field(i)
+		key := keyOf(field)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && key == field.Name {
+			err = walk(fieldValue.Addr().Interface(), section, f)
+			if err != nil {
+				return
+			}
+			continue
+		}
+		if field.PkgPath != "" || key == "" || key == "-" || field.Tag.Get(goconfig.Tag) == "-" ||
+			structtag.IsArg(&field) || structtag.IsCommand(&field) {
+			continue
+		}
+		switch {
+		case isValue(field.Type):
+			err = f(field, fieldValue, section, key)
+		case fieldValue.Kind() == reflect.Struct:
+			err = f(field, fieldValue, section, key)
+			if err == nil {
+				err = walk(fieldValue.Addr().Interface(), join(section, key), f)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// build returns the INI document of config, the help adds the cfgHelper
+// and cfgDefault of the fields as comments
+func build(config interface{}, help bool) (cfg *ini.File, err error) {
+	cfg = ini.Empty()
+	err = walk(config, "", func(field reflect.StructField, value reflect.Value, section, key string) (err error) {
+		comment := field.Tag.Get(goconfig.TagHelper)
+		if !isValue(field.Type) {
+			if help && comment != "" {
+				cfg.Section(join(section, key)).Comment = comment
+			}
+			return
+		}
+		defaultValue := field.Tag.Get(goconfig.TagDefault)
+		if structtag.IsDeprecated(&field) || value.Kind() == reflect.Ptr && value.IsNil() {
+			return
+		}
+		if !help && goconfig.SaveOmitDefaults {
+			if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+				if value.Len() == 0 {
+					return
+				}
+			} else if structtag.IsDefault(value, defaultValue) {
+				return
+			}
+		}
+		if section == "" {
+			section = ini.DefaultSection
+		}
+		k, err := cfg.Section(section).NewKey(key, formatValue(value))
+		if err != nil || !help {
+			return
+		}
+		if defaultValue != "" {
+			comment = strings.TrimSpace(fmt.Sprintf("%v (default %q)", comment, defaultValue))
+		}
+		k.Comment = comment
+		return
+	})
+	return
+}
+
+// EncodeINI writes config as an INI document to w, nested structs are sections
+func EncodeINI(w io.Writer, config interface{}) (err error) {
+	cfg, err := build(config, false)
+	if err != nil {
+		return
+	}
+	_, err = cfg.WriteTo(w)
+	return
+}
+
+// keyOf returns the name of the field from its ini tag, its cfg tag or its
+// name changed by goconfig.FileNaming, the cfg tag comes first with
+// goconfig.CfgFileKeys
+func keyOf(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("ini"), ",")[0]
+	if key != "" && !(goconfig.CfgFileKeys && field.Tag.Get(goconfig.Tag) != "") {
+		return key
+	}
+	key = field.Tag.Get(goconfig.Tag)
+	if key == "" {
+		key = field.Name
+	}
+	if field.Anonymous {
+		return key
+	}
+	return goconfig.FileKey(key)
+}
+
 // PrepareHelp return help string for this file format.
 func PrepareHelp(config interface{}) (help string, err error) {
-	var helpAux []byte
-	helpAux, err = json.MarshalIndent(&config, "", "    ")
+	cfg, err := build(config, true)
 	if err != nil {
 		return
 	}
-	help = string(helpAux)
+	var buf bytes.Buffer
+	_, err = cfg.WriteTo(&buf)
+	help = buf.String()
 	return
 }
@@ -0,0 +1,278 @@
+package ini
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h2oai/goconfig"
+)
+
+type tlsConfig struct {
+	Cert string `cfg:"cert"`
+}
+
+type mongoDB struct {
+	Host string    `cfg:"host"`
+	TLS  tlsConfig `cfg:"tls"`
+}
+
+type strictConfig struct {
+	Name    string  `cfg:"name" cfgAlias:"title"`
+	MongoDB mongoDB `cfg:"mongodb"`
+}
+
+func TestStrict(t *testing.T) {
+	goconfig.StrictFile = true
+	defer func() {
+		goconfig.StrictFile = false
+	}()
+
+	doc := `; hsot = commented
+name = hsot = in a value
+include = other.ini
+
+[mongodb]
+hsot = a
+
+[mongodb.tls]
+cert = c
+key = k
+
+[redis]
+host = r
+`
+	err := DecodeINI(strings.NewReader(doc), &strictConfig{})
+	if !errors.Is(err, goconfig.ErrUnknownKey) {
+		t.Fatal("expected ErrUnknownKey but got", err)
+	}
+	expected := "unknown config file key: mongodb.hsot (line 6), mongodb.tls.key (line 10), redis (line 12)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q but got %q", expected, err.Error())
+	}
+
+	c := &strictConfig{}
+	err = DecodeINI(strings.NewReader("title = x\n[mongodb]\nhost = a\n"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "x" || c.MongoDB.Host != "a" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	goconfig.StrictFile = false
+	err = DecodeINI(strings.NewReader(doc), &strictConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type server struct {
+	Host  string   `cfg:"host" cfgDefault:"localhost" cfgHelper:"the host"`
+	Ports []int    `cfg:"ports"`
+	Tags  []string `ini:"labels" cfg:"tags"`
+}
+
+type Embedded struct {
+	Level string `cfg:"level"`
+}
+
+type testConfig struct {
+	Embedded
+	Name    string    `cfg:"name"`
+	Debug   bool      `cfg:"debug" cfgDefault:"true"`
+	Ratio   float64   `cfg:"ratio"`
+	Hosts   []string  `cfg:"hosts"`
+	MongoDB server    `cfg:"mongodb" cfgHelper:"the database"`
+	TLS     tlsConfig `cfg:"tls"`
+	Admin   mongoDB   `cfg:"admin"`
+}
+
+func TestDecodeINI(t *testing.T) {
+	doc := `; the app
+level = debug
+name = app
+debug = false
+ratio = 0.5
+hosts = a, b
+
+[MongoDB]
+host = db
+ports = 80,443
+labels = x,y
+
+[tls]
+cert = a.pem
+
+[admin.tls]
+cert = b.pem
+`
+	c := &testConfig{}
+	err := DecodeINI(strings.NewReader(doc), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &testConfig{
+		Embedded: Embedded{Level: "debug"},
+		Name:     "app",
+		Ratio:    0.5,
+		Hosts:    []string{"a", "b"},
+		MongoDB:  server{Host: "db", Ports: []int{80, 443}, Tags: []string{"x", "y"}},
+		TLS:      tlsConfig{Cert: "a.pem"},
+		Admin:    mongoDB{TLS: tlsConfig{Cert: "b.pem"}},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, c)
+	}
+
+	err = DecodeINI(strings.NewReader("[mongodb]\nports = 80,many\n"), &testConfig{})
+	if err == nil || !strings.HasPrefix(err.Error(), "mongodb.ports: ") {
+		t.Fatal("expected an error naming the key but got", err)
+	}
+}
+
+func TestEncodeINI(t *testing.T) {
+	c := &testConfig{
+		Name:    "app",
+		Hosts:   []string{"a", "b"},
+		MongoDB: server{Host: "db", Ports: []int{80}, Tags: []string{}},
+		Admin:   mongoDB{TLS: tlsConfig{Cert: "b.pem"}},
+	}
+	var buf bytes.Buffer
+	err := EncodeINI(&buf, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"hosts = a,b", "[mongodb]", "ports  = 80", "[admin.tls]"} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("expected %v in\n%v", line, buf.String())
+		}
+	}
+
+	decoded := &testConfig{}
+	err = DecodeINI(&buf, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, decoded) {
+		t.Fatalf("expected %+v, got %+v", c, decoded)
+	}
+}
+
+func TestPrepareHelp(t *testing.T) {
+	help, err := PrepareHelp(&testConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{
+		"; the database\n[mongodb]\n",
+		"; the host (default \"localhost\")\nhost   = \n",
+		"labels = \n",
+		"[admin.tls]\n",
+	} {
+		if !strings.Contains(help, part) {
+			t.Fatalf("expected %q in\n%v", part, help)
+		}
+	}
+}
+
+type typesConfig struct {
+	Timeout   time.Duration   `cfg:"timeout"`
+	Retries   uint8           `cfg:"retries"`
+	Port      uint16          `cfg:"port"`
+	Size      uint64          `cfg:"size"`
+	Ratio     float32         `cfg:"ratio"`
+	Started   time.Time       `cfg:"started"`
+	Level     *int            `cfg:"level"`
+	Intervals []time.Duration `cfg:"intervals"`
+	Weights   []uint          `cfg:"weights"`
+	Paths     []string        `cfg:"paths"`
+	MongoDB   mongoDB         `cfg:"mongodb"`
+}
+
+func TestLoadINITypes(t *testing.T) {
+	goconfig.Path, goconfig.File = "fixtures", "types.ini"
+	defer func() {
+		goconfig.Path, goconfig.File = "./", ""
+	}()
+
+	c := &typesConfig{}
+	err := LoadINI(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level := 7
+	expected := &typesConfig{
+		Timeout:   5 * time.Second,
+		Retries:   3,
+		Port:      8080,
+		Size:      1 << 20,
+		Ratio:     0.25,
+		Started:   time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC),
+		Level:     &level,
+		Intervals: []time.Duration{time.Second, 500 * time.Millisecond},
+		Weights:   []uint{1, 2, 3},
+		Paths:     []string{"a,b", "c"},
+		MongoDB:   mongoDB{Host: "myhost"},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, c)
+	}
+
+	var buf bytes.Buffer
+	err = EncodeINI(&buf, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &typesConfig{}
+	err = DecodeINI(&buf, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, decoded) {
+		t.Fatalf("expected %+v, got %+v", c, decoded)
+	}
+
+	for _, doc := range []string{"retries = 256\n", "port = -1\n", "timeout = soon\n"} {
+		err = DecodeINI(strings.NewReader(doc), &typesConfig{})
+		if err == nil {
+			t.Errorf("expected an error for %q", doc)
+		}
+	}
+}
+
+func TestLoadINIExample(t *testing.T) {
+	type mongoDB struct {
+		Host string `ini:"host" cfg:"host"`
+		Port int    `ini:"port" cfg:"port"`
+	}
+	type systemUser struct {
+		Name     string `ini:"name" cfg:"name"`
+		Password string `ini:"passwd" cfg:"passwd"`
+	}
+	type configTest struct {
+		DebugMode bool `ini:"debug" cfg:"debug"`
+		Domain    string
+		User      systemUser `ini:"user" cfg:"user"`
+		MongoDB   mongoDB    `ini:"mongodb" cfg:"mongodb"`
+	}
+
+	byt, err := os.ReadFile(filepath.Join("..", "examples", "ini_config_file", "config.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &configTest{}
+	err = DecodeINI(bytes.NewReader(byt), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &configTest{DebugMode: true, Domain: "example.com", MongoDB: mongoDB{Host: "myhost", Port: 909}}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, c)
+	}
+}
@@ -0,0 +1,244 @@
+package goconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/structtag"
+)
+
+var (
+	// Interpolate expands the references in the string values loaded from
+	// the config file: ${VAR} and ${VAR:-default} are read from the
+	// environment and ${cfg:mongodb.host} from other config keys, named with
+	// the cfg tags joined by dots. $${ is kept as a literal ${.
+	Interpolate bool
+
+	// ErrInterpolation is returned for unknown keys, cycles and unterminated references
+	ErrInterpolation = errors.New("interpolation error")
+)
+
+type interpolation struct {
+	values   map[string]reflect.Value
+	names    map[string]string
+	keys     []string
+	loaded   map[string]bool
+	resolved map[string]bool
+	stack    []string
+}
+
+// loadInterpolated calls load and, with Interpolate set, expands the
+// references in the string values that load changed, the values set by
+// the defaults, the environment variables and the flags are kept
+func loadInterpolated(config interface{}, load func() error) (err error) {
+	if !Interpolate {
+		err = load()
+		return
+	}
+	in, err := collect(config)
+	if err != nil {
+		return
+	}
+	before := in.strings()
+	err = load()
+	if err != nil {
+		return
+	}
+	err = interpolate(config, before)
+	return
+}
+
+// interpolate expands the references in the string fields of config whose
+// value is not the one found in before, every field when before is nil
+func interpolate(config interface{}, before map[string]string) (err error) {
+	in, err := collect(config)
+	if err != nil {
+		return
+	}
+	for k, value := range in.strings() {
+		prev, ok := before[k]
+		in.loaded[k] = before == nil || !ok || prev != value
+	}
+
+	for _, key := range in.keys {
+		_, err = in.resolve(key)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// collect returns the values of config by their key, the cfg tags joined
+// by dots
+func collect(config interface{}) (in *interpolation, err error) {
+	in = &interpolation{
+		values:   make(map[string]reflect.Value),
+		names:    make(map[string]string),
+		loaded:   make(map[string]bool),
+		resolved: make(map[string]bool),
+	}
+	scalar := func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		in.add(tag, *value)
+		return
+	}
+	slice := func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		for i := 0; i < value.Len(); i++ {
+			key := fmt.Sprintf("%s[%d]", tag, i)
+			switch elem := value.Index(i); elem.Kind() {
+			case reflect.Struct:
+				err = structtag.Parse(elem.Addr().Interface(), key)
+				if err != nil {
+					return
+				}
+			case reflect.String:
+				in.add(key, elem)
+			}
+		}
+		return
+	}
+
+	// the options of goenv and goflags are kept for the next Parse
+	tag, tagDefault, tagDisabled := structtag.Tag, structtag.TagDefault, structtag.TagDisabled
+	separator, prefix, mapper, parseMap := structtag.TagSeparator, structtag.Prefix, structtag.Naming, structtag.ParseMap
+	defer func() {
+		structtag.Tag, structtag.TagDefault, structtag.TagDisabled = tag, tagDefault, tagDisabled
+		structtag.TagSeparator, structtag.Prefix, structtag.Naming, structtag.ParseMap = separator, prefix, mapper, parseMap
+	}()
+
+	structtag.Setup()
+	structtag.Prefix = ""
+	structtag.Tag = Tag
+	structtag.TagDefault = TagDefault
+	structtag.TagSeparator = "."
+	structtag.ParseMap[reflect.Int64] = scalar
+	structtag.ParseMap[reflect.Int] = scalar
+	structtag.ParseMap[reflect.Float64] = scalar
+	structtag.ParseMap[reflect.String] = scalar
+	structtag.ParseMap[reflect.Bool] = scalar
+	structtag.ParseMap[reflect.Array] = slice
+	structtag.ParseMap[reflect.Slice] = slice
+	err = structtag.Parse(config, "")
+	return
+}
+
+// strings returns the string values by their key in lower case
+func (in *interpolation) strings() (values map[string]string) {
+	values = make(map[string]string)
+	for k, value := range in.values {
+		if value.Kind() == reflect.String {
+			values[k] = value.String()
+		}
+	}
+	return
+}
+
+func (in *interpolation) add(key string, value reflect.Value) {
+	k := strings.ToLower(key)
+	in.values[k] = value
+	in.names[k] = key
+	in.keys = append(in.keys, key)
+}
+
+// resolve expands the value of key once and returns it as a string
+func (in *interpolation) resolve(key string) (ret string, err error) {
+	k := strings.ToLower(key)
+	value, ok := in.values[k]
+	if !ok {
+		err = fmt.Errorf("%w: unknown config key %q", ErrInterpolation, key)
+		return
+	}
+	if value.Kind() != reflect.String || in.resolved[k] || !in.loaded[k] {
+		ret = helper.FormatValue(value)
+		return
+	}
+	for i, s := range in.stack {
+		if s == in.names[k] {
+			cycle := append(in.stack[i:], s)
+			err = fmt.Errorf("%w: cycle %v", ErrInterpolation, strings.Join(cycle, " -> "))
+			return
+		}
+	}
+
+	in.stack = append(in.stack, in.names[k])
+	ret, err = in.expand(value.String(), in.names[k])
+	in.stack = in.stack[:len(in.stack)-1]
+	if err != nil {
+		return
+	}
+	if value.CanSet() {
+		value.SetString(ret)
+	}
+	in.resolved[k] = true
+	return
+}
+
+// expand replaces the references found in s, the value of key
+func (in *interpolation) expand(s, key string) (ret string, err error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		end := closingBrace(s, i+2)
+		if end < 0 {
+			err = fmt.Errorf("%w: unterminated ${ in %v", ErrInterpolation, key)
+			return
+		}
+		var v string
+		v, err = in.reference(s[i+2:end], key)
+		if err != nil {
+			return
+		}
+		b.WriteString(s[:i] + v)
+		s = s[end+1:]
+	}
+	ret = b.String()
+	return
+}
+
+// reference returns the value of a ${...} expression
+func (in *interpolation) reference(expr, key string) (ret string, err error) {
+	if strings.HasPrefix(expr, "cfg:") {
+		ret, err = in.resolve(expr[len("cfg:"):])
+		return
+	}
+	name, def, hasDefault := expr, "", false
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		name, def, hasDefault = expr[:i], expr[i+2:], true
+	}
+	ret, ok := os.LookupEnv(name)
+	if (!ok || ret == "") && hasDefault {
+		ret, err = in.expand(def, key)
+	}
+	return
+}
+
+// closingBrace returns the index of the } closing the reference starting at start
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
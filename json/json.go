@@ -1,21 +1,34 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 
 	"github.com/h2oai/goconfig"
 	"github.com/h2oai/goconfig/helper"
 )
 
 func init() {
-	f := goconfig.Fileformat{
+	err := goconfig.RegisterFormat(goconfig.Fileformat{
+		Name:        "json",
 		Extension:   ".json",
+		MIMETypes:   []string{"application/json"},
 		Load:        LoadJSON,
+		Decode:      DecodeJSON,
+		Encode:      EncodeJSON,
+		KeyOf:       keyOf,
 		PrepareHelp: PrepareHelp,
+	})
+	if err != nil {
+		panic(err)
 	}
-	goconfig.Formats = append(goconfig.Formats, f)
 }
 
 // LoadJSON config file
@@ -30,19 +43,176 @@ func LoadJSON(config interface{}) (err error) {
 	}
 	defer helper.Closer(file)
 
-	decoder := json.NewDecoder(file)
+	err = DecodeJSON(file, config)
+	return
+}
+
+// DecodeJSON reads the JSON document from r into config
+func DecodeJSON(r io.Reader, config interface{}) (err error) {
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	doc, err := preprocess(byt, config)
+	if err != nil {
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	if goconfig.StrictFile {
+		decoder.DisallowUnknownFields()
+	}
 	err = decoder.Decode(&config)
+	if err != nil && goconfig.StrictFile {
+		err = unknownFields(err, byt, config)
+	}
+	return
+}
+
+// preprocess applies the included files and the keys listed on cfgAlias to
+// the document byt, with goconfig.StrictFile the include key is removed
+func preprocess(byt []byte, config interface{}) (ret []byte, err error) {
+	ret = byt
+	var m map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(byt))
+	decoder.UseNumber()
+	if decoder.Decode(&m) != nil {
+		// let the struct decoding report the error
+		return
+	}
+	err = goconfig.Include(config, m)
 	if err != nil {
 		return
 	}
+	keys := goconfig.FileKeyOf(keyOf)
+	removed := goconfig.StrictFile && goconfig.RemoveIncludeKey(m)
+	renamed := goconfig.RenameAliases(config, m, keys)
+	if goconfig.RenameFileKeys(config, m, keyOf) || renamed || removed {
+		ret, err = json.Marshal(m)
+	}
+	return
+}
 
+const unknownFieldError = "json: unknown field "
+
+// unknownFields returns err, the error of DisallowUnknownFields, as
+// goconfig.ErrUnknownKey listing every unknown key of the document byt,
+// read before the aliases were renamed, with its path and its line
+func unknownFields(err error, byt []byte, config interface{}) error {
+	if !strings.HasPrefix(err.Error(), unknownFieldError) {
+		return err
+	}
+	var m map[string]interface{}
+	if json.Unmarshal(byt, &m) != nil {
+		return err
+	}
+	keys := goconfig.UnknownKeys(config, m, goconfig.FileKeyOf(keyOf))
+	if len(keys) == 0 {
+		return err
+	}
+	lines := keyLines(byt)
+	return goconfig.UnknownKeysError(keys, func(key string) int {
+		return lines[key]
+	})
+}
+
+// keyLines returns the line of the keys of the document byt by their path,
+// like servers[1].host
+func keyLines(byt []byte) (lines map[string]int) {
+	type level struct {
+		object    bool
+		expectKey bool
+		key       string
+		index     int
+	}
+	lines = make(map[string]int)
+	var stack []*level
+	decoder := json.NewDecoder(bytes.NewReader(byt))
+	for {
+		offset := decoder.InputOffset()
+		tok, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		var top *level
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if tok == json.Delim('}') || tok == json.Delim(']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 && stack[len(stack)-1].object {
+				stack[len(stack)-1].expectKey = true
+			}
+			continue
+		}
+		if top != nil && top.object && top.expectKey {
+			top.key, _ = tok.(string)
+			top.expectKey = false
+			path := ""
+			for _, l := range stack {
+				if !l.object {
+					path += fmt.Sprintf("[%d]", l.index)
+					continue
+				}
+				if path != "" {
+					path += "."
+				}
+				path += l.key
+			}
+			if _, ok := lines[path]; !ok {
+				offset += int64(bytes.IndexByte(byt[offset:], '"'))
+				lines[path] = bytes.Count(byt[:offset], []byte("\n")) + 1
+			}
+			continue
+		}
+		if top != nil && !top.object {
+			top.index++
+		}
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &level{object: true, expectKey: true})
+		case json.Delim('['):
+			stack = append(stack, &level{index: -1})
+		default:
+			if top != nil && top.object {
+				top.expectKey = true
+			}
+		}
+	}
+}
+
+// EncodeJSON writes config as an indented JSON document to w
+func EncodeJSON(w io.Writer, config interface{}) (err error) {
+	var v interface{} = config
+	if goconfig.SaveOmitDefaults {
+		v = goconfig.Values(config, goconfig.FileKeyOf(keyOf))
+	} else if goconfig.RenamedFileKeys() {
+		v = goconfig.Map(config, goconfig.FileKeyOf(keyOf))
+	}
+	byt, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return
+	}
+	_, err = w.Write(append(byt, '\n'))
 	return
 }
 
+func keyOf(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("json"), ",")[0]
+	if key == "" {
+		key = field.Name
+	}
+	return key
+}
+
 // PrepareHelp return help string for this file format.
 func PrepareHelp(config interface{}) (help string, err error) {
+	var v interface{} = &config
+	if goconfig.RenamedFileKeys() {
+		v = goconfig.Map(config, goconfig.FileKeyOf(keyOf))
+	}
 	var helpAux []byte
-	helpAux, err = json.MarshalIndent(&config, "", "    ")
+	helpAux, err = json.MarshalIndent(v, "", "    ")
 	if err != nil {
 		return
 	}
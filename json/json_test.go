@@ -0,0 +1,106 @@
+package json
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/goenv"
+)
+
+type server struct {
+	Host string `json:"host"`
+}
+
+type strictConfig struct {
+	Name    string   `json:"name" cfgAlias:"title"`
+	Servers []server `json:"servers"`
+	MongoDB server   `json:"mongodb"`
+}
+
+func TestStrict(t *testing.T) {
+	goconfig.StrictFile = true
+	defer func() {
+		goconfig.StrictFile = false
+	}()
+
+	doc := `{
+  "nmae": "hsot",
+  "include": [],
+  "servers": [
+    {"host": "a"},
+    {
+      "hsot": "b"
+    }
+  ],
+  "mongodb": {"hsot": "c"}
+}`
+	err := DecodeJSON(strings.NewReader(doc), &strictConfig{})
+	if !errors.Is(err, goconfig.ErrUnknownKey) {
+		t.Fatal("expected ErrUnknownKey but got", err)
+	}
+	expected := "unknown config file key: mongodb.hsot (line 10), nmae (line 2), servers[1].hsot (line 7)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q but got %q", expected, err.Error())
+	}
+
+	// the lines are found in the document before the aliases are renamed
+	err = DecodeJSON(strings.NewReader("{\n  \"title\": \"x\",\n  \"mongodb\": {\"hsot\": \"a\"}\n}"), &strictConfig{})
+	if err == nil || err.Error() != "unknown config file key: mongodb.hsot (line 3)" {
+		t.Fatal("unexpected error", err)
+	}
+
+	// the aliases and the include key are known
+	c := &strictConfig{}
+	err = DecodeJSON(strings.NewReader(`{"title": "x", "include": "other.json", "mongodb": {"host": "a"}}`), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "x" || c.MongoDB.Host != "a" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	goconfig.StrictFile = false
+	err = DecodeJSON(strings.NewReader(doc), &strictConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListFromFileAndEnv(t *testing.T) {
+	type listConfig struct {
+		Hosts []string `json:"hosts" cfg:"hosts"`
+		Ports []int    `json:"ports" cfg:"ports"`
+	}
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"hosts": ["a,b", " c", ""], "ports": [80]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goconfig.Path, goconfig.File = dir, "config.json"
+	defer func() {
+		goconfig.Path, goconfig.File = "./", ""
+	}()
+
+	c := &listConfig{}
+	err = LoadJSON(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("PORTS", "8080,8443")
+	defer os.Unsetenv("PORTS")
+	goenv.Setup("cfg", "cfgDefault")
+	err = goenv.Parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &listConfig{Hosts: []string{"a,b", " c", ""}, Ports: []int{8080, 8443}}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %q, got %q", expected, c)
+	}
+}
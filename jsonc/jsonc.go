@@ -0,0 +1,98 @@
+package jsonc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/helper"
+	gojson "github.com/h2oai/goconfig/json"
+)
+
+func init() {
+	err := goconfig.RegisterFormat(goconfig.Fileformat{
+		Name:        "jsonc",
+		Extension:   ".jsonc",
+		Extensions:  []string{".json5"},
+		MIMETypes:   []string{"application/jsonc", "application/json5"},
+		Comments:    true,
+		Load:        LoadJSONC,
+		Decode:      DecodeJSONC,
+		Encode:      gojson.EncodeJSON,
+		PrepareHelp: gojson.PrepareHelp,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Error is an error of a JSONC document with its position
+type Error struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// LoadJSONC config file
+func LoadJSONC(config interface{}) (err error) {
+	configFile := filepath.Join(goconfig.Path, goconfig.File)
+	file, err := os.Open(configFile)
+	if err != nil {
+		if os.IsNotExist(err) && !goconfig.FileRequired {
+			err = nil
+		}
+		return
+	}
+	defer helper.Closer(file)
+
+	err = DecodeJSONC(file, config)
+	return
+}
+
+// DecodeJSONC reads the JSONC or JSON5 document from r into config, the
+// fields are mapped like in the json package
+func DecodeJSONC(r io.Reader, config interface{}) (err error) {
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	s := &standardizer{src: byt}
+	err = s.run()
+	if err != nil {
+		return
+	}
+
+	err = gojson.DecodeJSON(bytes.NewReader(s.out.Bytes()), config)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		err = s.errorAt(s.srcOffset(syntaxErr.Offset-1), err)
+	case errors.As(err, &typeErr):
+		err = s.errorAt(s.srcOffset(typeErr.Offset-1), err)
+	}
+	return
+}
+
+// Standardize returns the JSON document equivalent to the JSONC or JSON5
+// document byt, keeping its lines
+func Standardize(byt []byte) (ret []byte, err error) {
+	s := &standardizer{src: byt}
+	err = s.run()
+	ret = s.out.Bytes()
+	return
+}
@@ -0,0 +1,109 @@
+package jsonc
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type server struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type testConfig struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Note    string   `json:"note"`
+	Hosts   []string `json:"hosts"`
+	Ratio   float64  `json:"ratio"`
+	Count   int      `json:"count"`
+	Enabled bool     `json:"enabled"`
+	MongoDB server   `json:"mongodb"`
+}
+
+func TestDecodeJSONC(t *testing.T) {
+	doc := `// the app
+{
+  /* the name, with a // marker */
+  name: 'app', // a comment
+  url: "http://example.com/*path*/",
+  note: 'it\'s "quoted" // not a comment',
+  hosts: ['a', "b",],
+  ratio: .5,
+  count: 0x10,
+  enabled: true,
+  "mongodb": {
+    host: 'localhost',
+    port: +27017, /* trailing */
+  },
+}
+`
+	c := &testConfig{}
+	err := DecodeJSONC(strings.NewReader(doc), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &testConfig{
+		Name:    "app",
+		URL:     "http://example.com/*path*/",
+		Note:    `it's "quoted" // not a comment`,
+		Hosts:   []string{"a", "b"},
+		Ratio:   0.5,
+		Count:   16,
+		Enabled: true,
+		MongoDB: server{Host: "localhost", Port: 27017},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, c)
+	}
+}
+
+func TestStandardize(t *testing.T) {
+	doc := "{\n  // comment\n  a: 'x', /* multi\n  line */ b: [1, 2,],\n}\n"
+	byt, err := Standardize([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(byt), "\n") != strings.Count(doc, "\n") {
+		t.Fatalf("the lines must be kept: %q", string(byt))
+	}
+	var m map[string]interface{}
+	err = json.Unmarshal(byt, &m)
+	if err != nil {
+		t.Fatalf("invalid JSON %q: %v", string(byt), err)
+	}
+	if m["a"] != "x" || len(m["b"].([]interface{})) != 2 {
+		t.Fatal("unexpected document", m)
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		doc    string
+		line   int
+		column int
+	}{
+		// syntax errors of the standardizer
+		{"{\n  name: 'app\n}", 2, 9},
+		{"{\n  /* open\n", 2, 3},
+		{"{\n  a: 1,\n  b: NaN\n}", 3, 6},
+		{"{\n  a: 1,\n  b: @\n}", 3, 6},
+		// errors of the JSON decoder mapped back to the document
+		{"{\n  // comment\n  a: 1\n  b: 2\n}", 4, 3},
+		{"{\n  /* comment */ count: 'ten'\n}", 2, 24},
+	}
+	for _, test := range tests {
+		err := DecodeJSONC(strings.NewReader(test.doc), &testConfig{})
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("expected an Error for %q but got %v", test.doc, err)
+			continue
+		}
+		if e.Line != test.line || e.Column != test.column {
+			t.Errorf("expected line %d, column %d for %q but got %v", test.line, test.column, test.doc, err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package jsonc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// standardizer rewrites a JSONC or JSON5 document as JSON, recording the
+// offset in src of each byte written to out
+type standardizer struct {
+	src       []byte
+	i         int
+	out       bytes.Buffer
+	pos       []int
+	stack     []byte
+	expectKey bool
+}
+
+func (s *standardizer) run() (err error) {
+	s.skip("\xef\xbb\xbf")
+	for {
+		err = s.space()
+		if err != nil || s.i >= len(s.src) {
+			return
+		}
+		c := s.src[s.i]
+		switch {
+		case c == '{' || c == '[':
+			s.stack = append(s.stack, c)
+			s.expectKey = c == '{'
+			s.copy(1)
+		case c == '}' || c == ']':
+			if len(s.stack) > 0 {
+				s.stack = s.stack[:len(s.stack)-1]
+			}
+			s.expectKey = false
+			s.copy(1)
+		case c == ',':
+			next, e := s.next(s.i + 1)
+			if e != nil {
+				err = e
+				return
+			}
+			if next < len(s.src) && (s.src[next] == '}' || s.src[next] == ']') {
+				// trailing comma
+				s.write(" ", s.i)
+				s.i++
+				continue
+			}
+			s.expectKey = len(s.stack) > 0 && s.stack[len(s.stack)-1] == '{'
+			s.copy(1)
+		case c == ':':
+			s.expectKey = false
+			s.copy(1)
+		case c == '"' || c == '\'':
+			err = s.string(c)
+		case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9':
+			err = s.number()
+		case isIdentifier(c, true):
+			err = s.identifier()
+		default:
+			r, _ := utf8.DecodeRune(s.src[s.i:])
+			err = s.errorAt(s.i, fmt.Errorf("invalid character %q", r))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// space copies the white space and removes the comments before the next value
+func (s *standardizer) space() (err error) {
+	next, err := s.next(s.i)
+	if err != nil {
+		return
+	}
+	for ; s.i < next; s.i++ {
+		switch c := s.src[s.i]; c {
+		case ' ', '\t', '\r', '\n':
+			s.write(string(c), s.i)
+		}
+	}
+	return
+}
+
+// next returns the offset of the first byte after i that is not white space or comment
+func (s *standardizer) next(i int) (next int, err error) {
+	for i < len(s.src) {
+		switch {
+		case s.src[i] == ' ' || s.src[i] == '\t' || s.src[i] == '\r' || s.src[i] == '\n':
+			i++
+		case bytes.HasPrefix(s.src[i:], []byte("//")):
+			end := bytes.IndexByte(s.src[i:], '\n')
+			if end < 0 {
+				return len(s.src), nil
+			}
+			i += end
+		case bytes.HasPrefix(s.src[i:], []byte("/*")):
+			end := bytes.Index(s.src[i+2:], []byte("*/"))
+			if end < 0 {
+				err = s.errorAt(i, errors.New("unterminated comment"))
+				return
+			}
+			i += end + 4
+		default:
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+// string writes the string starting at s.i as a double quoted JSON string
+func (s *standardizer) string(quote byte) (err error) {
+	start := s.i
+	var b strings.Builder
+	b.WriteByte('"')
+	for s.i++; s.i < len(s.src); s.i++ {
+		c := s.src[s.i]
+		switch {
+		case c == quote:
+			s.i++
+			b.WriteByte('"')
+			s.write(b.String(), start)
+			return
+		case c == '\\' && s.i+1 < len(s.src):
+			s.i++
+			switch e := s.src[s.i]; e {
+			case '\n':
+				// line continuation
+			case '\r':
+				if s.i+1 < len(s.src) && s.src[s.i+1] == '\n' {
+					s.i++
+				}
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			case 'v':
+				b.WriteString(`\u000b`)
+			case '0':
+				b.WriteString(`\u0000`)
+			case 'x':
+				if s.i+2 >= len(s.src) {
+					return s.errorAt(s.i-1, errors.New("invalid escape"))
+				}
+				n, e := strconv.ParseUint(string(s.src[s.i+1:s.i+3]), 16, 8)
+				if e != nil {
+					return s.errorAt(s.i-1, errors.New("invalid escape"))
+				}
+				fmt.Fprintf(&b, `\u%04x`, n)
+				s.i += 2
+			default:
+				b.WriteByte(e)
+			}
+		case c == '"':
+			b.WriteString(`\"`)
+		case c == '\n':
+			return s.errorAt(start, errors.New("newline in string"))
+		case c < 0x20:
+			fmt.Fprintf(&b, `\u%04x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	err = s.errorAt(start, errors.New("unterminated string"))
+	return
+}
+
+// number writes the number starting at s.i as a JSON number
+func (s *standardizer) number() (err error) {
+	start := s.i
+	for s.i < len(s.src) && (isIdentifier(s.src[s.i], false) || strings.IndexByte("+-.", s.src[s.i]) >= 0) {
+		s.i++
+	}
+	token := string(s.src[start:s.i])
+	sign := ""
+	if token[0] == '-' || token[0] == '+' {
+		sign, token = strings.TrimPrefix(token[:1], "+"), token[1:]
+	}
+	switch {
+	case token == "Infinity" || token == "NaN":
+		err = s.errorAt(start, fmt.Errorf("%v is not supported", token))
+		return
+	case strings.HasPrefix(token, "0x") || strings.HasPrefix(token, "0X"):
+		n, e := strconv.ParseUint(token[2:], 16, 64)
+		if e != nil {
+			err = s.errorAt(start, fmt.Errorf("invalid number %v", token))
+			return
+		}
+		token = strconv.FormatUint(n, 10)
+	default:
+		if strings.HasPrefix(token, ".") {
+			token = "0" + token
+		}
+		token = strings.Replace(strings.Replace(token, ".e", ".0e", 1), ".E", ".0E", 1)
+		if strings.HasSuffix(token, ".") {
+			token += "0"
+		}
+	}
+	s.write(sign+token, start)
+	return
+}
+
+// identifier writes the identifier starting at s.i as a key or a literal
+func (s *standardizer) identifier() (err error) {
+	start := s.i
+	for s.i < len(s.src) && isIdentifier(s.src[s.i], false) {
+		s.i++
+	}
+	id := string(s.src[start:s.i])
+	switch {
+	case s.expectKey:
+		s.write(`"`+id+`"`, start)
+	case id == "true" || id == "false" || id == "null":
+		s.write(id, start)
+	case id == "Infinity" || id == "NaN":
+		err = s.errorAt(start, fmt.Errorf("%v is not supported", id))
+	default:
+		err = s.errorAt(start, fmt.Errorf("invalid value %v", id))
+	}
+	return
+}
+
+func isIdentifier(c byte, first bool) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c >= 0x80 ||
+		!first && c >= '0' && c <= '9'
+}
+
+func (s *standardizer) skip(prefix string) {
+	if bytes.HasPrefix(s.src[s.i:], []byte(prefix)) {
+		s.i += len(prefix)
+	}
+}
+
+func (s *standardizer) copy(n int) {
+	s.write(string(s.src[s.i:s.i+n]), s.i)
+	s.i += n
+}
+
+func (s *standardizer) write(str string, at int) {
+	s.out.WriteString(str)
+	for range []byte(str) {
+		s.pos = append(s.pos, at)
+	}
+}
+
+// srcOffset returns the offset in src of the byte out of the JSON document
+func (s *standardizer) srcOffset(out int64) int {
+	switch {
+	case out < 0:
+		return 0
+	case out >= int64(len(s.pos)):
+		return len(s.src)
+	}
+	return s.pos[out]
+}
+
+// errorAt returns err with the line and column of the offset in src
+func (s *standardizer) errorAt(offset int, err error) error {
+	line := bytes.Count(s.src[:offset], []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(s.src[:offset], '\n') + 1
+	return &Error{
+		Line:   line,
+		Column: utf8.RuneCount(s.src[lineStart:offset]) + 1,
+		Err:    err,
+	}
+}
@@ -0,0 +1,88 @@
+package goconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/h2oai/goconfig/helper"
+)
+
+// ErrDecodeNotSupported is returned when the file format has no Decode function
+var ErrDecodeNotSupported = errors.New("file format does not support decoding")
+
+// LoadReader reads the settings of config from r with the format found by
+// LookupFormat for ext, like ".yaml" or "application/json", instead of the
+// config file. Interpolate is applied, call it before Parse with File empty
+// to have the environment variables and flags override the values.
+func LoadReader(config interface{}, r io.Reader, ext string) (err error) {
+	err = loadInterpolated(config, func() error {
+		return decode(config, r, ext, "")
+	})
+	return
+}
+
+// LoadBytes reads the settings of config from byt like LoadReader
+func LoadBytes(config interface{}, byt []byte, ext string) (err error) {
+	err = LoadReader(config, bytes.NewReader(byt), ext)
+	return
+}
+
+// LoadFS reads the settings of config from the file name of fsys, like an
+// embed.FS, with the format matching its extension like LoadReader. The
+// files it includes are read from fsys.
+func LoadFS(config interface{}, fsys fs.FS, name string) (err error) {
+	prev := loadingFS
+	loadingFS = fsys
+	defer func() {
+		loadingFS = prev
+	}()
+
+	err = loadInterpolated(config, func() error {
+		return loadFS(config, name)
+	})
+	return
+}
+
+// loadFS reads the file name of loadingFS
+func loadFS(config interface{}, name string) (err error) {
+	for i, f := range loading {
+		if f == name {
+			err = fmt.Errorf("%w: %v", ErrIncludeCycle, strings.Join(append(loading[i:], name), " -> "))
+			return
+		}
+	}
+	file, err := loadingFS.Open(name)
+	if err != nil {
+		return
+	}
+	defer helper.Closer(file)
+
+	err = decode(config, file, path.Ext(name), name)
+	return
+}
+
+// decode reads r with the format found by LookupFormat for ext, name is the
+// path of the document its includes are relative to, empty for a reader
+func decode(config interface{}, r io.Reader, ext, name string) (err error) {
+	format, ok := LookupFormat(ext)
+	if !ok {
+		err = fmt.Errorf("%v: %w", ext, ErrFileFormatNotDefined)
+		return
+	}
+	if !format.Has(CanDecode) {
+		err = fmt.Errorf("%v: %w", ext, ErrDecodeNotSupported)
+		return
+	}
+	loading = append(loading, name)
+	defer func() {
+		loading = loading[:len(loading)-1]
+	}()
+
+	err = format.Decode(r, config)
+	return
+}
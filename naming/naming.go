@@ -0,0 +1,82 @@
+package naming
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Mapper turns a field name or a cfg tag into the name used by a source,
+// like the flags or the environment variables
+type Mapper func(name string) string
+
+// Words splits name at underscores, dashes, dots, spaces and changes of
+// case. A run of capitals is kept as one word, so HTTPServerURL becomes
+// HTTP, Server and URL, and digits stay with the word before them.
+func Words(name string) (words []string) {
+	runes := []rune(name)
+	start := -1
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == '.' || unicode.IsSpace(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return
+}
+
+// Snake returns name in snake_case, like max_conn_count
+func Snake(name string) string {
+	return join(name, "_", strings.ToLower)
+}
+
+// ScreamingSnake returns name in SCREAMING_SNAKE_CASE, like MAX_CONN_COUNT
+func ScreamingSnake(name string) string {
+	return join(name, "_", strings.ToUpper)
+}
+
+// Kebab returns name in kebab-case, like max-conn-count
+func Kebab(name string) string {
+	return join(name, "-", strings.ToLower)
+}
+
+// Camel returns name in camelCase, like maxConnCount, acronyms are
+// capitalized like the other words
+func Camel(name string) string {
+	words := Words(name)
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if i > 0 {
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+			word = string(runes)
+		}
+		words[i] = word
+	}
+	return strings.Join(words, "")
+}
+
+// join returns the words of name changed by f and joined by sep
+func join(name, sep string, f func(string) string) string {
+	words := Words(name)
+	for i, word := range words {
+		words[i] = f(word)
+	}
+	return strings.Join(words, sep)
+}
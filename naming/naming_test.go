@@ -0,0 +1,46 @@
+package naming
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := map[string][]string{
+		"MaxConnCount":  {"Max", "Conn", "Count"},
+		"HTTPServerURL": {"HTTP", "Server", "URL"},
+		"userID":        {"user", "ID"},
+		"v2Api":         {"v2", "Api"},
+		"max-conn_id":   {"max", "conn", "id"},
+		"ID":            {"ID"},
+		"":              nil,
+	}
+	for name, expected := range tests {
+		if words := Words(name); !reflect.DeepEqual(words, expected) {
+			t.Errorf("Words(%q) expected %q but got %q", name, expected, words)
+		}
+	}
+}
+
+func TestMappers(t *testing.T) {
+	tests := []struct {
+		mapper   Mapper
+		name     string
+		expected string
+	}{
+		{Snake, "MaxConnCount", "max_conn_count"},
+		{Snake, "HTTPServerURL", "http_server_url"},
+		{ScreamingSnake, "MaxConnCount", "MAX_CONN_COUNT"},
+		{ScreamingSnake, "max-conn", "MAX_CONN"},
+		{Kebab, "HTTPServerURL", "http-server-url"},
+		{Kebab, "max_conn", "max-conn"},
+		{Camel, "HTTPServerURL", "httpServerUrl"},
+		{Camel, "max_conn_count", "maxConnCount"},
+		{Camel, "maxConnCount", "maxConnCount"},
+	}
+	for _, test := range tests {
+		if name := test.mapper(test.name); name != test.expected {
+			t.Errorf("expected %q for %q but got %q", test.expected, test.name, name)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package goconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/structtag"
+)
+
+// Edit is a change made by Patch, Keys is the path of the value in the
+// document from its root
+type Edit struct {
+	Keys  []string
+	Value interface{}
+}
+
+// ErrPatch is returned when a value cannot be patched
+var ErrPatch = errors.New("cannot patch")
+
+// Patch sets the values of config listed in values, keyed by the cfg tags
+// joined by dots like mongodb.host, and writes them to the file name. The
+// formats with a Patch function, like YAML and TOML, only change the lines
+// of those values and keep the comments and the order of the document, the
+// others load the file and save it whole.
+func Patch(config interface{}, name string, values map[string]interface{}) (err error) {
+	format, err := fileFormat(name)
+	if err != nil {
+		return
+	}
+
+	if !format.Has(CanPatch) {
+		if !format.Has(CanDecode) {
+			err = fmt.Errorf("%v: %w", format.Extension, ErrDecodeNotSupported)
+			return
+		}
+		var file *os.File
+		file, err = os.Open(name)
+		if err != nil {
+			return
+		}
+		err = format.Decode(file, config)
+		helper.Closer(file)
+		if err != nil {
+			return
+		}
+		for path, v := range values {
+			_, err = patchField(config, format, path, v)
+			if err != nil {
+				return
+			}
+		}
+		err = Save(config, name)
+		return
+	}
+
+	var edits []Edit
+	for path, v := range values {
+		var edit Edit
+		edit, err = patchField(config, format, path, v)
+		if err != nil {
+			return
+		}
+		edits = append(edits, edit)
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return strings.Join(edits[i].Keys, ".") < strings.Join(edits[j].Keys, ".")
+	})
+
+	byt, err := ioutil.ReadFile(name)
+	if err != nil {
+		return
+	}
+	byt, err = format.Patch(byt, edits)
+	if err != nil {
+		return
+	}
+	err = writeFile(name, byt)
+	return
+}
+
+// patchField sets the field of config at path to v and returns the edit of
+// the document keys of the format
+func patchField(config interface{}, format Fileformat, path string, v interface{}) (edit Edit, err error) {
+	value := reflect.ValueOf(config)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	for _, part := range strings.Split(path, ".") {
+		if value.Kind() != reflect.Struct {
+			err = fmt.Errorf("%w %v: %v is not a struct", ErrPatch, path, part)
+			return
+		}
+		field, ok := lookupField(value.Type(), part)
+		if !ok {
+			err = fmt.Errorf("%w %v: unknown field %v", ErrPatch, path, part)
+			return
+		}
+		key := field.Tag.Get(Tag)
+		if format.KeyOf != nil {
+			key = FileKeyOf(format.KeyOf)(field)
+		}
+		if key == "" {
+			key = field.Name
+		}
+		edit.Keys = append(edit.Keys, key)
+		value = value.FieldByIndex(field.Index)
+	}
+
+	err = setField(value, v)
+	if err != nil {
+		err = fmt.Errorf("%w %v: %v", ErrPatch, path, err)
+		return
+	}
+	edit.Value = value.Interface()
+	return
+}
+
+// lookupField returns the field of t named name by its cfg tag or its name, ignoring case
+func lookupField(t reflect.Type, name string) (field reflect.StructField, ok bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field = t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if strings.EqualFold(field.Tag.Get(Tag), name) || strings.EqualFold(field.Name, name) {
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+// setField sets value to v, converting it within its kind of values or
+// parsing it when v is a string
+func setField(value reflect.Value, v interface{}) (err error) {
+	rv := reflect.ValueOf(v)
+	if rv.IsValid() && convertible(rv, value.Type()) {
+		value.Set(rv.Convert(value.Type()))
+		return
+	}
+	s, ok := v.(string)
+	if !ok {
+		err = fmt.Errorf("%T %v is not assignable to %v", v, v, value.Type())
+		return
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int64:
+		if value.Type() == reflect.TypeOf(time.Duration(0)) {
+			var d time.Duration
+			d, err = time.ParseDuration(s)
+			value.SetInt(int64(d))
+			return
+		}
+		var i int64
+		i, err = strconv.ParseInt(s, 10, 64)
+		value.SetInt(i)
+	case reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, 64)
+		value.SetFloat(f)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		value.SetBool(b)
+	default:
+		err = fmt.Errorf("%w: %v", structtag.ErrTypeNotSupported, value.Type())
+	}
+	return
+}
+
+// convertible returns true if rv converts to t without changing its value:
+// strings to strings, integers to integers they fit in or to floats and
+// floats to floats
+func convertible(rv reflect.Value, t reflect.Type) bool {
+	if !rv.Type().ConvertibleTo(t) {
+		return false
+	}
+	from, to := rv.Kind(), t.Kind()
+	switch {
+	case isInt(from) && isInt(to):
+		return !reflect.Zero(t).OverflowInt(rv.Int())
+	case isUint(from) && isUint(to):
+		return !reflect.Zero(t).OverflowUint(rv.Uint())
+	case isInt(from) || isUint(from):
+		return isFloat(to)
+	case isFloat(from):
+		return isFloat(to)
+	}
+	return from == to
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUint(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func isFloat(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
@@ -0,0 +1,152 @@
+package properties
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// parse reads the properties of the document byt, following the rules of
+// java.util.Properties: # and ! comments, line continuations, keys ending at
+// the first unescaped =, : or white space and backslash escapes
+func parse(byt []byte) (props []property, err error) {
+	lines := strings.Split(strings.TrimPrefix(string(byt), "\ufeff"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(strings.TrimSuffix(lines[i], "\r"), " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		start := i + 1
+		for continues(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(strings.TrimSuffix(lines[i], "\r"), " \t\f")
+		}
+		if continues(line) {
+			line = line[:len(line)-1]
+		}
+
+		var p property
+		p.line = start
+		rawKey, rawValue := splitProperty(line)
+		p.key, err = unescape(rawKey)
+		if err == nil {
+			p.value, err = unescape(rawValue)
+		}
+		if err != nil {
+			err = fmt.Errorf("line %d: %w", start, err)
+			return
+		}
+		props = append(props, p)
+	}
+	return
+}
+
+// continues returns true if line ends with an odd number of backslashes
+func continues(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// splitProperty splits line at the first unescaped separator
+func splitProperty(line string) (key, value string) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == '=' || line[i] == ':' || line[i] == ' ' || line[i] == '\t' || line[i] == '\f' {
+			end = i
+			break
+		}
+	}
+	key = line[:end]
+	value = strings.TrimLeft(line[end:], " \t\f")
+	if value != "" && (value[0] == '=' || value[0] == ':') {
+		value = strings.TrimLeft(value[1:], " \t\f")
+	}
+	return
+}
+
+// unescape replaces the backslash escapes of s
+func unescape(s string) (ret string, err error) {
+	if !strings.Contains(s, `\`) {
+		ret = s
+		return
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				err = fmt.Errorf("invalid escape %q", s[i-1:])
+				return
+			}
+			var r uint64
+			r, err = strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				err = fmt.Errorf("invalid escape %q", s[i-1:i+5])
+				return
+			}
+			i += 4
+			// a character outside the BMP is written as a UTF-16 surrogate pair
+			if utf16.IsSurrogate(rune(r)) && i+7 <= len(s) && s[i+1:i+3] == `\u` {
+				if low, e := strconv.ParseUint(s[i+3:i+7], 16, 16); e == nil {
+					if c := utf16.DecodeRune(rune(r), rune(low)); c != unicode.ReplacementChar {
+						r = uint64(c)
+						i += 6
+					}
+				}
+			}
+			b.WriteRune(rune(r))
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	ret = b.String()
+	return
+}
+
+// escape returns s written as a key or a value of a .properties document
+func escape(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			b.WriteString(`\ `)
+		case key && (r == '=' || r == ':' || r == '#' || r == '!'):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,225 @@
+package properties
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/structtag"
+)
+
+func init() {
+	err := goconfig.RegisterFormat(goconfig.Fileformat{
+		Name:        "properties",
+		Extension:   ".properties",
+		MIMETypes:   []string{"text/x-java-properties"},
+		Comments:    true,
+		Load:        LoadProperties,
+		Decode:      DecodeProperties,
+		Encode:      EncodeProperties,
+		KeyOf:       keyOf,
+		PrepareHelp: PrepareHelp,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// property is a key and its value defined on line
+type property struct {
+	key   string
+	value string
+	line  int
+}
+
+// LoadProperties config file
+func LoadProperties(config interface{}) (err error) {
+	configFile := filepath.Join(goconfig.Path, goconfig.File)
+	file, err := os.Open(configFile)
+	if err != nil {
+		if os.IsNotExist(err) && !goconfig.FileRequired {
+			err = nil
+		}
+		return
+	}
+	defer helper.Closer(file)
+
+	err = DecodeProperties(file, config)
+	return
+}
+
+// DecodeProperties reads the .properties document from r into config, the
+// keys are the cfg tags of the nested fields joined by dots like mongodb.host
+// and lists are comma-separated
+func DecodeProperties(r io.Reader, config interface{}) (err error) {
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	props, err := parse(byt)
+	if err != nil {
+		return
+	}
+
+	values := make(map[string]property, len(props))
+	m := make(map[string]interface{})
+	for _, p := range props {
+		values[strings.ToLower(p.key)] = p
+		nest(m, strings.Split(p.key, "."), p.value)
+	}
+	if goconfig.StrictFile {
+		err = goconfig.UnknownKeysError(goconfig.UnknownKeys(config, m, keyOf), func(key string) int {
+			return values[strings.ToLower(key)].line
+		})
+		if err != nil {
+			return
+		}
+	}
+	err = goconfig.Include(config, m)
+	if err != nil {
+		return
+	}
+
+	err = walk(config, func(field *reflect.StructField, value *reflect.Value, key string) (err error) {
+		p, ok := values[strings.ToLower(key)]
+		if ok && structtag.IsDeprecated(field) {
+			goconfig.Warn(structtag.Deprecation(field, fmt.Sprintf("config file key %q", key), ""))
+		}
+		for _, alias := range structtag.Aliases(field, key) {
+			if ok {
+				break
+			}
+			p, ok = values[strings.ToLower(alias)]
+			if ok {
+				goconfig.Warn(structtag.Deprecation(field,
+					fmt.Sprintf("config file key %q", alias),
+					fmt.Sprintf("%q", key)))
+			}
+		}
+		if !ok {
+			return
+		}
+		err = helper.ParseValue(*value, p.value)
+		if err != nil {
+			err = fmt.Errorf("%v (line %d): %w", p.key, p.line, err)
+		}
+		return
+	})
+	return
+}
+
+// nest sets value in m at the nested keys, used to check the keys
+func nest(m map[string]interface{}, keys []string, value string) {
+	for _, key := range keys[:len(keys)-1] {
+		sub, ok := m[key].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[key] = sub
+		}
+		m = sub
+	}
+	if _, ok := m[keys[len(keys)-1]].(map[string]interface{}); !ok {
+		m[keys[len(keys)-1]] = value
+	}
+}
+
+// EncodeProperties writes config as key=value lines to w
+func EncodeProperties(w io.Writer, config interface{}) (err error) {
+	err = write(w, config, false)
+	return
+}
+
+func keyOf(field reflect.StructField) string {
+	key := field.Tag.Get(goconfig.Tag)
+	if key == "" {
+		key = field.Name
+	}
+	return goconfig.FileKey(key)
+}
+
+// PrepareHelp return help string for this file format.
+func PrepareHelp(config interface{}) (help string, err error) {
+	var buf bytes.Buffer
+	err = write(&buf, config, true)
+	help = buf.String()
+	return
+}
+
+// write writes the values of config as key=value lines, with comments
+// showing cfgHelper and cfgDefault
+func write(w io.Writer, config interface{}, comments bool) (err error) {
+	bw := bufio.NewWriter(w)
+	err = walk(config, func(field *reflect.StructField, value *reflect.Value, key string) (err error) {
+		defaultValue := field.Tag.Get(goconfig.TagDefault)
+		if structtag.IsDeprecated(field) ||
+			!comments && goconfig.SaveOmitDefaults && structtag.IsDefault(*value, defaultValue) {
+			return
+		}
+		if comments {
+			if help := field.Tag.Get(goconfig.TagHelper); help != "" {
+				fmt.Fprintf(bw, "# %v\n", help)
+			}
+			if defaultValue != "" {
+				fmt.Fprintf(bw, "# default: %v\n", defaultValue)
+			}
+		}
+		fmt.Fprintf(bw, "%v=%v\n", escape(key, true), escape(helper.FormatList(*value), false))
+		return
+	})
+	if err != nil {
+		return
+	}
+	err = bw.Flush()
+	return
+}
+
+// walk calls f for the scalar fields and the lists of scalars of config
+// with their keys
+func walk(config interface{}, f structtag.ReflectFunc) (err error) {
+	slice := func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		switch value.Type().Elem().Kind() {
+		case reflect.Int, reflect.Int64, reflect.Float64, reflect.String, reflect.Bool:
+			err = f(field, value, tag)
+		}
+		return
+	}
+	scalar := func(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
+		if structtag.IsArg(field) {
+			return
+		}
+		err = f(field, value, tag)
+		return
+	}
+
+	// the options of goenv and goflags are kept for the next Parse
+	tag, tagDefault, tagDisabled := structtag.Tag, structtag.TagDefault, structtag.TagDisabled
+	separator, prefix, mapper, parseMap := structtag.TagSeparator, structtag.Prefix, structtag.Naming, structtag.ParseMap
+	defer func() {
+		structtag.Tag, structtag.TagDefault, structtag.TagDisabled = tag, tagDefault, tagDisabled
+		structtag.TagSeparator, structtag.Prefix, structtag.Naming, structtag.ParseMap = separator, prefix, mapper, parseMap
+	}()
+
+	structtag.Setup()
+	structtag.Prefix = ""
+	structtag.Tag = goconfig.Tag
+	structtag.TagDefault = goconfig.TagDefault
+	structtag.TagSeparator = "."
+	structtag.Naming = goconfig.FileKey
+	structtag.ParseMap[reflect.Int64] = scalar
+	structtag.ParseMap[reflect.Int] = scalar
+	structtag.ParseMap[reflect.Float64] = scalar
+	structtag.ParseMap[reflect.String] = scalar
+	structtag.ParseMap[reflect.Bool] = scalar
+	structtag.ParseMap[reflect.Array] = slice
+	structtag.ParseMap[reflect.Slice] = slice
+	err = structtag.Parse(config, "")
+	return
+}
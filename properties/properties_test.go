@@ -0,0 +1,174 @@
+package properties
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/naming"
+	"github.com/h2oai/goconfig/structtag"
+)
+
+type tlsConfig struct {
+	Cert string `cfg:"cert"`
+}
+
+type mongoDB struct {
+	Host string    `cfg:"host" cfgDefault:"localhost" cfgHelper:"the host"`
+	Port int       `cfg:"port"`
+	TLS  tlsConfig `cfg:"tls"`
+}
+
+type testConfig struct {
+	Name    string   `cfg:"name"`
+	Title   string   `cfg:"title"`
+	Path    string   `cfg:"path"`
+	Debug   bool     `cfg:"debug"`
+	Ratio   float64  `cfg:"ratio"`
+	Hosts   []string `cfg:"hosts"`
+	Ports   []int    `cfg:"ports"`
+	MongoDB mongoDB  `cfg:"mongodb"`
+}
+
+func TestDecodeProperties(t *testing.T) {
+	doc := `# a comment
+! another comment
+name=app
+title : the \
+    long title
+path   C:\\temp\u00e9
+debug:true
+ratio = 0.5
+hosts=a,\
+      b
+ports = 80, 443
+mongodb.host = db
+mongodb.port=27017
+mongodb.tls.cert=a.pem
+`
+	c := &testConfig{}
+	err := DecodeProperties(strings.NewReader(doc), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &testConfig{
+		Name:    "app",
+		Title:   "the long title",
+		Path:    `C:\tempé`,
+		Debug:   true,
+		Ratio:   0.5,
+		Hosts:   []string{"a", "b"},
+		Ports:   []int{80, 443},
+		MongoDB: mongoDB{Host: "db", Port: 27017, TLS: tlsConfig{Cert: "a.pem"}},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, c)
+	}
+
+	err = DecodeProperties(strings.NewReader("name=app\nmongodb.port=many\n"), &testConfig{})
+	if err == nil || !strings.Contains(err.Error(), "mongodb.port (line 2)") {
+		t.Fatal("expected an error naming the key and its line but got", err)
+	}
+	c = &testConfig{}
+	err = DecodeProperties(strings.NewReader("name=\\uD83D\\uDE00 \\uD83D\\u0041\n"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "\U0001F600 \uFFFDA" {
+		t.Fatalf("expected the surrogate pair as one rune, got %q", c.Name)
+	}
+	err = DecodeProperties(strings.NewReader("name=\\u00zz\n"), &testConfig{})
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatal("expected an invalid escape error but got", err)
+	}
+}
+
+func TestEncodeProperties(t *testing.T) {
+	c := &testConfig{
+		Name:    "my app",
+		Path:    `C:\temp`,
+		Hosts:   []string{"a", "b"},
+		Ports:   []int{},
+		MongoDB: mongoDB{Host: "db", TLS: tlsConfig{Cert: "a=b.pem"}},
+	}
+	var buf bytes.Buffer
+	err := EncodeProperties(&buf, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"name=my app", `path=C:\\temp`, "hosts=a,b", "mongodb.host=db", "mongodb.tls.cert=a=b.pem"} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("expected %v in\n%v", line, buf.String())
+		}
+	}
+
+	decoded := &testConfig{}
+	err = DecodeProperties(&buf, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, decoded) {
+		t.Fatalf("expected %+v, got %+v", c, decoded)
+	}
+}
+
+func TestPrepareHelp(t *testing.T) {
+	help, err := PrepareHelp(&testConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# the host\n# default: localhost\nmongodb.host=\n"
+	if !strings.Contains(help, expected) {
+		t.Fatalf("expected %q in\n%v", expected, help)
+	}
+}
+
+func TestNamingAndAliases(t *testing.T) {
+	type namingConfig struct {
+		MaxConn int    `cfgAlias:"MaxConnections"`
+		DBHost  string `cfg:"db_host"`
+	}
+
+	goconfig.FileNaming = naming.Kebab
+	var warnings []string
+	goconfig.Warn = func(msg string) {
+		warnings = append(warnings, msg)
+	}
+	defer func() {
+		goconfig.FileNaming = nil
+		goconfig.Warn = helper.Warn
+	}()
+
+	c := &namingConfig{}
+	err := DecodeProperties(strings.NewReader("max-connections=5\ndb-host=db\n"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxConn != 5 || c.DBHost != "db" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `"max-connections" is deprecated, use "max-conn"`) {
+		t.Fatal("unexpected warnings", warnings)
+	}
+}
+
+func TestWalkKeepsStructtag(t *testing.T) {
+	structtag.Setup()
+	structtag.Tag = "env"
+	structtag.TagSeparator = "__"
+	structtag.Prefix = "APP"
+	parseMap := structtag.ParseMap
+	defer structtag.Setup()
+
+	_, err := PrepareHelp(&testConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if structtag.Tag != "env" || structtag.TagSeparator != "__" || structtag.Prefix != "APP" ||
+		structtag.Naming != nil || reflect.ValueOf(structtag.ParseMap).Pointer() != reflect.ValueOf(parseMap).Pointer() {
+		t.Fatal("the structtag options must be restored")
+	}
+}
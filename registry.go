@@ -0,0 +1,181 @@
+package goconfig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Capability is a feature of a file format
+type Capability uint
+
+const (
+	// CanDecode is set for the formats with a Decode function
+	CanDecode Capability = 1 << iota
+
+	// CanEncode is set for the formats with an Encode function
+	CanEncode
+
+	// CanPatch is set for the formats with a Patch function
+	CanPatch
+
+	// SupportsComments is set for the formats with Comments
+	SupportsComments
+)
+
+var (
+	// ErrFormatConflict is returned by RegisterFormat when the name, an
+	// extension or a MIME type of the format is already registered by a
+	// format with the same priority
+	ErrFormatConflict = errors.New("file format already registered")
+
+	// ErrFormatInvalid is returned by RegisterFormat for a format without
+	// extension or Load function
+	ErrFormatInvalid = errors.New("invalid file format")
+)
+
+// RegisterFormat adds format to Formats. When another format uses its
+// name, an extension or a MIME type, the format with the higher Priority is
+// kept whatever the order of registration, so a format losing to a higher
+// priority one is not added and returns no error. Formats with the same
+// priority fail with ErrFormatConflict.
+func RegisterFormat(format Fileformat) (err error) {
+	format, err = normalizeFormat(format)
+	if err != nil {
+		return
+	}
+	for _, f := range Formats {
+		key, ok := formatConflict(f, format)
+		if !ok || f.Priority < format.Priority {
+			continue
+		}
+		if f.Priority == format.Priority {
+			err = fmt.Errorf("%w: %v is used by %v", ErrFormatConflict, key, f.name())
+		}
+		return
+	}
+	replaceFormat(format)
+	return
+}
+
+// ReplaceFormat adds format to Formats, removing the formats using its
+// name, an extension or a MIME type whatever their priority
+func ReplaceFormat(format Fileformat) (err error) {
+	format, err = normalizeFormat(format)
+	if err != nil {
+		return
+	}
+	replaceFormat(format)
+	return
+}
+
+// replaceFormat adds the normalized format to Formats, removing the formats
+// conflicting with it
+func replaceFormat(format Fileformat) {
+	formats := Formats[:0:0]
+	for _, f := range Formats {
+		if _, ok := formatConflict(f, format); !ok {
+			formats = append(formats, f)
+		}
+	}
+	Formats = append(formats, format)
+}
+
+// UnregisterFormat removes the format registered with name
+func UnregisterFormat(name string) (ok bool) {
+	for i, f := range Formats {
+		if strings.EqualFold(f.name(), name) {
+			Formats = append(Formats[:i:i], Formats[i+1:]...)
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+// LookupFormat returns the registered format matching key, a name like
+// "yaml", an extension like ".yml" or a MIME type like "application/yaml"
+func LookupFormat(key string) (format Fileformat, ok bool) {
+	key = strings.TrimSpace(strings.SplitN(key, ";", 2)[0])
+	if key == "" {
+		return
+	}
+	for _, f := range Formats {
+		for _, k := range f.keys() {
+			if strings.EqualFold(k, key) || strings.EqualFold(k, "."+key) {
+				format, ok = f, true
+				return
+			}
+		}
+	}
+	return
+}
+
+// Capabilities returns the features of the format
+func (f Fileformat) Capabilities() (c Capability) {
+	if f.Decode != nil {
+		c |= CanDecode
+	}
+	if f.Encode != nil {
+		c |= CanEncode
+	}
+	if f.Patch != nil {
+		c |= CanPatch
+	}
+	if f.Comments {
+		c |= SupportsComments
+	}
+	return
+}
+
+// Has reports whether the format has every capability of c
+func (f Fileformat) Has(c Capability) bool {
+	return f.Capabilities()&c == c
+}
+
+func (f Fileformat) name() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return strings.TrimPrefix(f.Extension, ".")
+}
+
+func (f Fileformat) extensions() []string {
+	if f.Extension == "" {
+		return f.Extensions
+	}
+	return append([]string{f.Extension}, f.Extensions...)
+}
+
+// keys returns the name, the extensions and the MIME types of the format
+func (f Fileformat) keys() []string {
+	keys := append([]string{f.name()}, f.extensions()...)
+	return append(keys, f.MIMETypes...)
+}
+
+// normalizeFormat sets the Name and the Extension of format
+func normalizeFormat(format Fileformat) (ret Fileformat, err error) {
+	ret = format
+	if ret.Extension == "" && len(ret.Extensions) > 0 {
+		ret.Extension, ret.Extensions = ret.Extensions[0], ret.Extensions[1:]
+	}
+	if ret.Extension == "" || ret.Load == nil {
+		err = fmt.Errorf("%w: %q needs an extension and a Load function", ErrFormatInvalid, ret.Name)
+		return
+	}
+	ret.Name = ret.name()
+	return
+}
+
+// formatConflict returns the first key shared by a and b
+func formatConflict(a, b Fileformat) (key string, ok bool) {
+	for _, ka := range a.keys() {
+		for _, kb := range b.keys() {
+			if strings.EqualFold(ka, kb) {
+				key, ok = ka, true
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,158 @@
+package goconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/h2oai/goconfig/structtag"
+)
+
+var (
+	// SaveOmitDefaults leaves out of the saved file the values equal to
+	// their cfgDefault, or zero for the fields without one
+	SaveOmitDefaults bool
+
+	// ErrEncodeNotSupported is returned when the file format has no Encode function
+	ErrEncodeNotSupported = errors.New("file format does not support encoding")
+)
+
+// Save writes config to the file name with the format matching its
+// extension, or Format when the extension is unknown. The file is replaced
+// atomically, keeping its mode, or created with mode 0644.
+func Save(config interface{}, name string) (err error) {
+	format, err := fileFormat(name)
+	if err != nil {
+		return
+	}
+	if !format.Has(CanEncode) {
+		err = fmt.Errorf("%v: %w", format.Extension, ErrEncodeNotSupported)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = format.Encode(&buf, config)
+	if err != nil {
+		return
+	}
+	err = writeFile(name, buf.Bytes())
+	return
+}
+
+// writeFile replaces the file name atomically with byt, keeping its mode
+func writeFile(name string, byt []byte) (err error) {
+	mode := os.FileMode(0644)
+	if info, e := os.Stat(name); e == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	_, err = tmp.Write(byt)
+	if err != nil {
+		return
+	}
+	err = tmp.Chmod(mode)
+	if err != nil {
+		return
+	}
+	err = tmp.Sync()
+	if err != nil {
+		return
+	}
+	err = tmp.Close()
+	if err != nil {
+		return
+	}
+	err = os.Rename(tmp.Name(), name)
+	return
+}
+
+// Values is used by the file formats to encode config when
+// SaveOmitDefaults is set. It returns the values of config as nested maps
+// keyed by keyOf, without the values equal to their default. Embedded
+// structs are flattened and lists of structs become lists of maps.
+func Values(config interface{}, keyOf func(field reflect.StructField) string) (m map[string]interface{}) {
+	value := reflect.ValueOf(config)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	m = make(map[string]interface{})
+	if value.Kind() == reflect.Struct {
+		values(value, keyOf, m, SaveOmitDefaults)
+	}
+	return
+}
+
+// Map returns all the values of config as nested maps keyed by keyOf like
+// Values, used by the file formats to encode config with CfgFileKeys
+func Map(config interface{}, keyOf func(field reflect.StructField) string) (m map[string]interface{}) {
+	value := reflect.ValueOf(config)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	m = make(map[string]interface{})
+	if value.Kind() == reflect.Struct {
+		values(value, keyOf, m, false)
+	}
+	return
+}
+
+func values(value reflect.Value, keyOf func(field reflect.StructField) string, m map[string]interface{}, omit bool) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldValue := value.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && keyOf(field) == field.Name {
+			values(fieldValue, keyOf, m, omit)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		key := keyOf(field)
+		if key == "" || key == "-" {
+			continue
+		}
+
+		switch fieldValue.Kind() {
+		case reflect.Struct:
+			sub := make(map[string]interface{})
+			values(fieldValue, keyOf, sub, omit)
+			if len(sub) > 0 || !omit {
+				m[key] = sub
+			}
+		case reflect.Slice, reflect.Array:
+			if omit && fieldValue.Len() == 0 {
+				continue
+			}
+			if fieldValue.Type().Elem().Kind() != reflect.Struct {
+				m[key] = fieldValue.Interface()
+				continue
+			}
+			list := make([]interface{}, fieldValue.Len())
+			for j := range list {
+				item := make(map[string]interface{})
+				values(fieldValue.Index(j), keyOf, item, omit)
+				list[j] = item
+			}
+			m[key] = list
+		default:
+			if omit && structtag.IsDefault(fieldValue, field.Tag.Get(TagDefault)) {
+				continue
+			}
+			m[key] = fieldValue.Interface()
+		}
+	}
+}
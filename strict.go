@@ -0,0 +1,229 @@
+package goconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/h2oai/goconfig/goenv"
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/structtag"
+)
+
+// Modes of StrictEnv
+const (
+	// StrictOff ignores the unknown environment variables
+	StrictOff = iota
+	// StrictWarn reports the unknown environment variables through Warn
+	StrictWarn
+	// StrictError makes Parse return ErrUnknownEnv
+	StrictError
+)
+
+var (
+	// StrictEnv reports the environment variables starting with PrefixEnv
+	// that do not match any field, usually typos like $APP_MONGODB_HSOT.
+	// Requires PrefixEnv, one of StrictOff, StrictWarn or StrictError.
+	StrictEnv = StrictOff
+
+	// ErrUnknownEnv is returned by Parse when StrictEnv is StrictError and unknown variables are set
+	ErrUnknownEnv = errors.New("unknown environment variable")
+
+	// StrictFile makes the file formats fail when the config file has keys
+	// that do not match any field, usually typos ignored otherwise
+	StrictFile bool
+
+	// ErrUnknownKey is returned by the file formats when StrictFile is set and the file has unknown keys
+	ErrUnknownKey = errors.New("unknown config file key")
+)
+
+// checkEnv looks for the unknown environment variables using the prefix
+func checkEnv(config interface{}) (err error) {
+	if StrictEnv == StrictOff || PrefixEnv == "" {
+		return
+	}
+
+	known, err := envNames(config, PrefixEnv)
+	if err != nil {
+		return
+	}
+	pref := PrefixEnv + structtag.TagSeparator
+	known = append(known, strings.ToUpper(pref+FileEnv), strings.ToUpper(pref+PathEnv), strings.ToUpper(pref+FormatEnv))
+
+	knownMap := make(map[string]bool, len(known))
+	for _, name := range known {
+		knownMap[name] = true
+	}
+
+	var unknown []string
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if !strings.HasPrefix(strings.ToUpper(name), strings.ToUpper(pref)) || knownMap[name] {
+			continue
+		}
+		msg := "$" + name
+		if suggestion := helper.Suggest(name, known); suggestion != "" {
+			msg += fmt.Sprintf(", did you mean $%v?", suggestion)
+		}
+		unknown = append(unknown, msg)
+	}
+	if len(unknown) == 0 {
+		return
+	}
+	sort.Strings(unknown)
+
+	if StrictEnv == StrictError {
+		err = fmt.Errorf("%w %v", ErrUnknownEnv, strings.Join(unknown, "; "))
+		return
+	}
+	for _, msg := range unknown {
+		Warn(fmt.Sprintf("%v %v", ErrUnknownEnv, msg))
+	}
+	return
+}
+
+// envNames returns the environment variables of config and of all its
+// subcommands
+func envNames(config interface{}, prefix string) (names []string, err error) {
+	setupEnv(prefix)
+	names, err = goenv.Names(config)
+	if err != nil {
+		return
+	}
+
+	list, err := listCommands(config)
+	if err != nil {
+		return
+	}
+	for _, cmd := range list {
+		var sub []string
+		sub, err = envNames(cmd.Value.Addr().Interface(), cmd.prefixEnv(prefix))
+		if err != nil {
+			return
+		}
+		names = append(names, sub...)
+	}
+	return
+}
+
+// UnknownKeys is used by the file formats to support StrictFile. It returns
+// the keys of the decoded document m that do not match any field of config,
+// as returned by keyOf, or any of their old names. Nested keys are joined
+// with dots and the items of lists have their index, like servers[1].host.
+func UnknownKeys(config interface{}, m interface{}, keyOf func(field reflect.StructField) string) (keys []string) {
+	keys = unknownKeys(reflect.TypeOf(config), reflect.ValueOf(m), keyOf, "")
+	sort.Strings(keys)
+	return
+}
+
+func unknownKeys(t reflect.Type, m reflect.Value, keyOf func(field reflect.StructField) string, path string) (keys []string) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for m.Kind() == reflect.Interface || m.Kind() == reflect.Ptr {
+		m = m.Elem()
+	}
+
+	switch m.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < m.Len(); i++ {
+			item := fmt.Sprintf("%v[%d].", strings.TrimSuffix(path, "."), i)
+			keys = append(keys, unknownKeys(t, m.Index(i), keyOf, item)...)
+		}
+		return
+	case reflect.Map:
+	default:
+		return
+	}
+
+	fields := make(map[string]reflect.StructField)
+	knownFields(t, keyOf, fields)
+	for _, k := range m.MapKeys() {
+		name := fmt.Sprint(k.Interface())
+		field, ok := fields[strings.ToLower(name)]
+		if !ok && path == "" && IncludeKey != "" && strings.EqualFold(name, IncludeKey) {
+			continue
+		}
+		if !ok {
+			keys = append(keys, path+name)
+			continue
+		}
+		keys = append(keys, unknownKeys(field.Type, m.MapIndex(k), keyOf, path+name+".")...)
+	}
+	return
+}
+
+// knownFields adds the keys of the fields of t to fields, embedded structs
+// are flattened
+func knownFields(t reflect.Type, keyOf func(field reflect.StructField) string, fields map[string]reflect.StructField) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			knownFields(field.Type, keyOf, fields)
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		key := keyOf(field)
+		if key == "" || key == "-" {
+			continue
+		}
+		fields[strings.ToLower(key)] = field
+		for _, alias := range strings.Split(field.Tag.Get(structtag.TagAlias), ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				fields[strings.ToLower(alias)] = field
+			}
+		}
+	}
+}
+
+// UnknownKeysError returns the error listing keys with the line returned by
+// lineOf, lines lower than 1 are omitted, or nil when keys is empty
+func UnknownKeysError(keys []string, lineOf func(key string) int) (err error) {
+	if len(keys) == 0 {
+		return
+	}
+	list := make([]string, len(keys))
+	for i, key := range keys {
+		list[i] = key
+		if line := lineOf(key); line > 0 {
+			list[i] = fmt.Sprintf("%v (line %d)", key, line)
+		}
+	}
+	err = fmt.Errorf("%w: %v", ErrUnknownKey, strings.Join(list, ", "))
+	return
+}
+
+// RemoveIncludeKey is used by the file formats decoding StrictFile with the
+// strict mode of their library. It removes IncludeKey, read by Include, from
+// the top level of the decoded document m and returns true when m changed.
+func RemoveIncludeKey(m interface{}) (removed bool) {
+	if IncludeKey == "" {
+		return
+	}
+	value := reflect.ValueOf(m)
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Map {
+		return
+	}
+	key, ok := lookupKey(value, IncludeKey)
+	if !ok {
+		return
+	}
+	value.SetMapIndex(key, reflect.Value{})
+	removed = true
+	return
+}
+
+// IsIncludeKey returns true if the top level key name is IncludeKey
+func IsIncludeKey(name string) bool {
+	return IncludeKey != "" && strings.EqualFold(name, IncludeKey)
+}
@@ -4,6 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/h2oai/goconfig/naming"
 )
 
 // ReflectFunc type used to create funcrions to parse struct and tags
@@ -37,6 +41,27 @@ var (
 	// TagDisabled used to not process an input
 	TagDisabled string
 
+	// TagAlias lists comma separated old names that still populate the field
+	TagAlias = "cfgAlias"
+
+	// TagDeprecated marks a deprecated field, its value is shown in the deprecation warning
+	TagDeprecated = "cfgDeprecated"
+
+	// TagAtFile allows the value of the field to be read from a file with the @path syntax
+	TagAtFile = "cfgAtFile"
+
+	// TagSecret allows the value of the field to be read from the file named by the <VAR>_FILE environment variable
+	TagSecret = "cfgSecret"
+
+	// TagArg binds the field to a positional argument, by index or "rest" for the remaining arguments
+	TagArg = "cfgArg"
+
+	// TagCommand marks a sub-structure as a subcommand, it is not parsed with the other fields
+	TagCommand = "cfgCommand"
+
+	// TagOneOf lists the comma separated values accepted by a field, checked by validate and used to complete its value
+	TagOneOf = "cfgOneOf"
+
 	// TagSeparator separe names on environment variables
 	TagSeparator string
 
@@ -48,12 +73,16 @@ var (
 
 	// Convert kebabcase (dashes) cmd args to snakecase (underscores) environment variables
 	KebabCfgToSnakeEnv bool
+
+	// Naming maps the prefix and each name joined in the generated tags, the names are kept as they are when nil
+	Naming naming.Mapper
 )
 
 // Setup maps and variables
 func Setup() {
 	TagDisabled = "-"
 	TagSeparator = "_"
+	Naming = nil
 
 	ParseMap = make(map[reflect.Kind]ReflectFunc)
 
@@ -92,7 +121,7 @@ func Parse(s interface{}, superTag string) (err error) {
 		value := refValue.Field(i)
 		kind := field.Type.Kind()
 
-		if field.PkgPath != "" {
+		if field.PkgPath != "" || IsCommand(&field) {
 			continue
 		}
 
@@ -176,16 +205,70 @@ func updateTag(field *reflect.StructField, superTag string) (ret string) {
 	if ret == "" {
 		ret = field.Name
 	}
+	ret = mapName(ret)
 	if superTag != "" {
 		ret = superTag + TagSeparator + ret
 		return
 	}
 	if Prefix != "" {
-		ret = Prefix + TagSeparator + ret
+		ret = mapName(Prefix) + TagSeparator + ret
 	}
 	return
 }
 
+// mapName returns name changed by Naming
+func mapName(name string) string {
+	if Naming == nil {
+		return name
+	}
+	return Naming(name)
+}
+
+// Aliases returns the names listed on TagAlias for the field, replacing the
+// field name at the end of tag by each alias
+func Aliases(field *reflect.StructField, tag string) (aliases []string) {
+	aliasTag := field.Tag.Get(TagAlias)
+	if aliasTag == "" {
+		return
+	}
+	name := field.Tag.Get(Tag)
+	if name == "" {
+		name = field.Name
+	}
+	base := strings.TrimSuffix(tag, mapName(name))
+	for _, alias := range strings.Split(aliasTag, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias != "" {
+			aliases = append(aliases, base+mapName(alias))
+		}
+	}
+	return
+}
+
+// Deprecation returns the warning shown when a deprecated name is used
+// instead of replacement
+func Deprecation(field *reflect.StructField, name, replacement string) string {
+	if msg := field.Tag.Get(TagDeprecated); msg != "" {
+		return fmt.Sprintf("%v is deprecated: %v", name, msg)
+	}
+	return fmt.Sprintf("%v is deprecated, use %v", name, replacement)
+}
+
+// IsDeprecated returns true if the field is tagged with TagDeprecated
+func IsDeprecated(field *reflect.StructField) bool {
+	return field.Tag.Get(TagDeprecated) != ""
+}
+
+// IsArg returns true if the field is a positional argument tagged with TagArg
+func IsArg(field *reflect.StructField) bool {
+	return field.Tag.Get(TagArg) != ""
+}
+
+// IsCommand returns true if the field is a subcommand tagged with TagCommand
+func IsCommand(field *reflect.StructField) bool {
+	return field.Tag.Get(TagCommand) != ""
+}
+
 // ReflectStruct is called when the Parse encounters a sub-structure in the current structure and then calls Parser again to treat the fields of the sub-structure.
 func ReflectStruct(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
 	err = Parse(value.Addr().Interface(), tag)
@@ -210,3 +293,28 @@ func ReflectArray(field *reflect.StructField, value *reflect.Value, tag string)
 	}
 	return
 }
+
+// IsDefault returns true if the scalar value equals defaultValue, the zero
+// value is the default of the fields without one
+func IsDefault(value reflect.Value, defaultValue string) bool {
+	if defaultValue == "" {
+		return value.IsZero()
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return value.String() == defaultValue
+	case reflect.Bool:
+		b, err := strconv.ParseBool(defaultValue)
+		return err == nil && value.Bool() == b
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(defaultValue, 10, 64)
+		return err == nil && value.Int() == i
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(defaultValue, 10, 64)
+		return err == nil && value.Uint() == u
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(defaultValue, 64)
+		return err == nil && value.Float() == f
+	}
+	return false
+}
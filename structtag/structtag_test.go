@@ -174,3 +174,27 @@ func TestSupertag(t *testing.T) {
 		}
 	}
 }
+
+func TestIsDefault(t *testing.T) {
+	cases := []struct {
+		value        interface{}
+		defaultValue string
+		expected     bool
+	}{
+		{"", "", true},
+		{"a", "", false},
+		{"a", "a", true},
+		{0, "", true},
+		{8080, "8080", true},
+		{8080, "80", false},
+		{1.5, "1.50", true},
+		{true, "t", true},
+		{false, "true", false},
+		{3, "invalid", false},
+	}
+	for _, c := range cases {
+		if got := IsDefault(reflect.ValueOf(c.value), c.defaultValue); got != c.expected {
+			t.Errorf("IsDefault(%v, %q) = %v, expected %v", c.value, c.defaultValue, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package toml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/h2oai/goconfig"
+)
+
+// Patch changes the values of the TOML document doc line by line, keeping
+// the comments and the order of the keys. Missing keys are added at the end
+// of their table, or next to the dotted keys of their parent, missing
+// tables at the end of the document. It returns goconfig.ErrPatch when a
+// parent of a key is an inline table or another value.
+func Patch(doc []byte, edits []goconfig.Edit) (ret []byte, err error) {
+	eol := "\n"
+	if bytes.Contains(doc, []byte("\r\n")) {
+		eol = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(string(doc), "\r\n", "\n"), "\n")
+	for _, edit := range edits {
+		var value string
+		value, err = formatValue(edit.Value)
+		if err != nil {
+			return
+		}
+		lines, err = patchLines(lines, edit.Keys, value)
+		if err != nil {
+			return
+		}
+	}
+	ret = []byte(strings.Join(lines, eol))
+	return
+}
+
+// patchLines sets the value of the nested keys, written as a key of its
+// table or as a dotted key of a parent table
+func patchLines(lines []string, keys []string, value string) (ret []string, err error) {
+	for i := len(keys) - 1; i >= 0; i-- {
+		start, end, ok := table(lines, keys[:i])
+		if !ok {
+			continue
+		}
+		for j := start; j < end; j++ {
+			k, _, found := splitKey(lines[j])
+			if found && equalKeys(k, keys[i:]) {
+				ret = replaceValue(lines, j, value)
+				return
+			}
+		}
+	}
+
+	// the parents defined by the keys of their own parent tables
+	for i := len(keys) - 2; i >= 0; i-- {
+		start, end, ok := table(lines, keys[:i])
+		if !ok {
+			continue
+		}
+		rel, dotted := keys[i:], -1
+		for j := start; j < end; j++ {
+			k, _, found := splitKey(lines[j])
+			switch {
+			case !found:
+			case len(k) < len(rel) && equalKeys(k, rel[:len(k)]):
+				err = fmt.Errorf("%w %v: %v is not a table", goconfig.ErrPatch,
+					strings.Join(keys, "."), strings.Join(keys[:i+len(k)], "."))
+				return
+			case len(k) > 1 && strings.EqualFold(k[0], rel[0]):
+				dotted = j
+			}
+		}
+		if dotted >= 0 {
+			key := make([]string, len(rel))
+			for j, k := range rel {
+				key[j] = quoteKey(k)
+			}
+			ret = append([]string{}, lines[:dotted+1]...)
+			ret = append(ret, indentOf(lines[dotted])+strings.Join(key, ".")+" = "+value)
+			ret = append(ret, lines[dotted+1:]...)
+			return
+		}
+	}
+
+	key := quoteKey(keys[len(keys)-1])
+	start, end, ok := table(lines, keys[:len(keys)-1])
+	if !ok {
+		header := make([]string, len(keys)-1)
+		for i, k := range keys[:len(keys)-1] {
+			header[i] = quoteKey(k)
+		}
+		at := len(lines)
+		if at > 0 && lines[at-1] == "" {
+			// keep the final newline
+			at--
+		}
+		added := []string{"", "[" + strings.Join(header, ".") + "]", key + " = " + value}
+		ret = append(lines[:at], append(added, lines[at:]...)...)
+		return
+	}
+	at := start
+	for i := start; i < end; i++ {
+		if isContent(lines[i]) {
+			at = i + 1
+		}
+	}
+	if at == len(lines) && at > 0 && lines[at-1] == "" {
+		at--
+	}
+	indent := ""
+	if at > start {
+		indent = indentOf(lines[at-1])
+	}
+	ret = append([]string{}, lines[:at]...)
+	ret = append(ret, indent+key+" = "+value)
+	ret = append(ret, lines[at:]...)
+	return
+}
+
+// indentOf returns the spaces and tabs at the start of line
+func indentOf(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// table returns the lines of the table named by keys, the lines before the
+// first header for the root table
+func table(lines []string, keys []string) (start, end int, ok bool) {
+	ok = len(keys) == 0
+	for i, line := range lines {
+		name, isHeader := header(line)
+		if !isHeader {
+			continue
+		}
+		if ok {
+			end = i
+			return
+		}
+		if name != nil && equalKeys(name, keys) {
+			start, ok = i+1, true
+		}
+	}
+	end = len(lines)
+	return
+}
+
+// header returns the keys of a [table] line, nil for an [[array]] line
+func header(line string) (keys []string, ok bool) {
+	line = strings.TrimSpace(line)
+	if c := commentIndex(line); c >= 0 {
+		line = strings.TrimSpace(line[:c])
+	}
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return
+	}
+	ok = true
+	if strings.HasPrefix(line, "[[") {
+		return
+	}
+	keys = splitDotted(line[1 : len(line)-1])
+	return
+}
+
+// splitKey splits a "key = value" line
+func splitKey(line string) (keys []string, rest string, ok bool) {
+	if !isContent(line) || strings.HasPrefix(strings.TrimSpace(line), "[") {
+		return
+	}
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			keys, rest, ok = splitDotted(line[:i]), line[i+1:], true
+			return
+		}
+	}
+	return
+}
+
+// splitDotted splits a dotted key removing the quotes
+func splitDotted(key string) (keys []string) {
+	var quote byte
+	var part strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+				continue
+			}
+			part.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			keys = append(keys, strings.TrimSpace(part.String()))
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	keys = append(keys, strings.TrimSpace(part.String()))
+	return
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceValue sets the value of the key defined on line i, removing the
+// following lines of a multi-line array and keeping the comment
+func replaceValue(lines []string, i int, value string) []string {
+	line := lines[i]
+	_, rest, _ := splitKey(line)
+	prefix := line[:len(line)-len(rest)]
+
+	end := i + 1
+	old := strings.TrimSpace(rest)
+	comment := ""
+	if c := commentIndex(rest); c >= 0 {
+		comment = rest[len(strings.TrimRight(rest[:c], " \t")):]
+		old = strings.TrimSpace(rest[:c])
+	}
+	depth := brackets(old)
+	for depth > 0 && end < len(lines) {
+		depth += brackets(lines[end])
+		comment = ""
+		end++
+	}
+	if comment != "" && !strings.HasPrefix(comment, " ") && !strings.HasPrefix(comment, "\t") {
+		comment = " " + comment
+	}
+
+	lines[i] = strings.TrimRight(prefix, " \t") + " " + value + comment
+	return append(lines[:i+1], lines[end:]...)
+}
+
+// brackets returns the number of arrays opened and not closed on line
+func brackets(line string) (depth int) {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return
+}
+
+// commentIndex returns the index of the comment of line, or -1
+func commentIndex(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return i
+		}
+	}
+	return -1
+}
+
+func isContent(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#")
+}
+
+// quoteKey quotes key when it is not a bare key
+func quoteKey(key string) string {
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return strconv.Quote(key)
+		}
+	}
+	return key
+}
+
+// formatValue returns the single line TOML representation of v
+func formatValue(v interface{}) (ret string, err error) {
+	if d, ok := v.(time.Duration); ok {
+		v = d.String()
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.String:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		err = encoder.Encode(value.String())
+		ret = strings.TrimSuffix(buf.String(), "\n")
+	case reflect.Bool:
+		ret = strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		ret = strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		ret = strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		ret = strconv.FormatFloat(f, 'f', -1, value.Type().Bits())
+		switch {
+		case math.IsInf(f, 0) || math.IsNaN(f):
+			ret = strings.ToLower(ret)
+		case !strings.Contains(ret, "."):
+			// whole numbers are read back as integers without a fraction
+			ret += ".0"
+		}
+	case reflect.Slice, reflect.Array:
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i], err = formatValue(value.Index(i).Interface())
+			if err != nil {
+				return
+			}
+		}
+		ret = "[" + strings.Join(items, ", ") + "]"
+	default:
+		err = fmt.Errorf("%w: %v values", goconfig.ErrPatch, value.Kind())
+	}
+	return
+}
@@ -0,0 +1,114 @@
+package toml
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/h2oai/goconfig"
+	"github.com/pelletier/go-toml"
+)
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		doc      string
+		edits    []goconfig.Edit
+		expected string
+	}{
+		{
+			"# app\nname = \"old\" # the name\n\n[mongodb]\nhost = \"localhost\"\nport = 1\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "port"}, Value: 27017}, {Keys: []string{"name"}, Value: "app"}},
+			"# app\nname = \"app\" # the name\n\n[mongodb]\nhost = \"localhost\"\nport = 27017\n",
+		},
+		{
+			"name = \"app\"\n\n[mongodb]\n  host = \"localhost\"\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "port"}, Value: 1}, {Keys: []string{"timeout"}, Value: time.Second}},
+			"name = \"app\"\ntimeout = \"1s\"\n\n[mongodb]\n  host = \"localhost\"\n  port = 1\n",
+		},
+		{
+			"name = \"app\"\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "tls", "cert"}, Value: "a.pem"}},
+			"name = \"app\"\n\n[mongodb.tls]\ncert = \"a.pem\"\n",
+		},
+		{
+			"hosts = [\n  \"a\",\n  \"b\",\n]\nname = \"app\"\n",
+			[]goconfig.Edit{{Keys: []string{"hosts"}, Value: []string{"c"}}},
+			"hosts = [\"c\"]\nname = \"app\"\n",
+		},
+		{
+			"name = \"app\"\nmongodb.host = \"localhost\"\nmongodb.tls.cert = \"a.pem\"\n\n[other]\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "host"}, Value: "db"}, {Keys: []string{"mongodb", "port"}, Value: 1}},
+			"name = \"app\"\nmongodb.host = \"db\"\nmongodb.tls.cert = \"a.pem\"\nmongodb.port = 1\n\n[other]\n",
+		},
+		{
+			"[mongodb]\ntls.cert = \"a.pem\"\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "tls", "key"}, Value: "a.key"}},
+			"[mongodb]\ntls.cert = \"a.pem\"\ntls.key = \"a.key\"\n",
+		},
+		{
+			"name = \"old\"\r\n\r\n[mongodb]\r\nhost = \"localhost\" # the host\r\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "host"}, Value: "db"}, {Keys: []string{"mongodb", "port"}, Value: 1}},
+			"name = \"old\"\r\n\r\n[mongodb]\r\nhost = \"db\" # the host\r\nport = 1\r\n",
+		},
+	}
+	for _, test := range tests {
+		byt, err := Patch([]byte(test.doc), test.edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(byt) != test.expected {
+			t.Errorf("expected %q but got %q", test.expected, string(byt))
+		}
+		if _, err = toml.LoadBytes(byt); err != nil {
+			t.Errorf("invalid document %q: %v", string(byt), err)
+		}
+	}
+}
+
+func TestPatchFloat(t *testing.T) {
+	type floatConfig struct {
+		Ratio float64 `toml:"ratio"`
+		Scale float32 `toml:"scale"`
+		Limit float64 `toml:"limit"`
+		Step  float64 `toml:"step"`
+	}
+
+	doc := "ratio = 0.5\nscale = 1.5\n"
+	edits := []goconfig.Edit{
+		{Keys: []string{"ratio"}, Value: 3.0},
+		{Keys: []string{"scale"}, Value: float32(0.1)},
+		{Keys: []string{"limit"}, Value: math.Inf(1)},
+		{Keys: []string{"step"}, Value: 1e21},
+	}
+	byt, err := Patch([]byte(doc), edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "ratio = 3.0\nscale = 0.1\nlimit = +inf\nstep = 1000000000000000000000.0\n"
+	if string(byt) != expected {
+		t.Fatalf("expected %q but got %q", expected, string(byt))
+	}
+
+	c := &floatConfig{}
+	err = toml.Unmarshal(byt, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Ratio != 3 || c.Scale != 0.1 || !math.IsInf(c.Limit, 1) || c.Step != 1e21 {
+		t.Fatalf("unexpected config %+v", c)
+	}
+}
+
+func TestPatchNotTable(t *testing.T) {
+	for _, doc := range []string{
+		"mongodb = { host = \"localhost\" }\n",
+		"mongodb = \"localhost\"\n",
+		"[mongodb]\ntls = { cert = \"a.pem\" }\n",
+	} {
+		_, err := Patch([]byte(doc), []goconfig.Edit{{Keys: []string{"mongodb", "tls", "cert"}, Value: "b.pem"}})
+		if !errors.Is(err, goconfig.ErrPatch) {
+			t.Errorf("expected ErrPatch for %q but got %v", doc, err)
+		}
+	}
+}
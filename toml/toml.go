@@ -1,44 +1,212 @@
 package toml
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/helper"
 	"github.com/pelletier/go-toml"
 )
 
 func init() {
-	f := goconfig.Fileformat{
+	err := goconfig.RegisterFormat(goconfig.Fileformat{
+		Name:        "toml",
 		Extension:   ".toml",
+		MIMETypes:   []string{"application/toml"},
+		Comments:    true,
 		Load:        LoadTOML,
+		Decode:      DecodeTOML,
+		Encode:      EncodeTOML,
+		KeyOf:       keyOf,
+		Patch:       Patch,
 		PrepareHelp: PrepareHelp,
+	})
+	if err != nil {
+		panic(err)
 	}
-	goconfig.Formats = append(goconfig.Formats, f)
 }
 
 // LoadTOML config file
 func LoadTOML(config interface{}) (err error) {
 	configFile := filepath.Join(goconfig.Path, goconfig.File)
-	_, err = os.Stat(configFile)
+	file, err := os.Open(configFile)
 	if err != nil {
 		if os.IsNotExist(err) && !goconfig.FileRequired {
 			err = nil
 		}
 		return
 	}
-	var tree *toml.Tree
-	tree, err = toml.LoadFile(configFile)
+	defer helper.Closer(file)
+
+	err = DecodeTOML(file, config)
+	return
+}
+
+// DecodeTOML reads the TOML document from r into config
+func DecodeTOML(r io.Reader, config interface{}) (err error) {
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	tree, err := toml.LoadBytes(byt)
+	if err != nil {
+		return
+	}
+	m := tree.ToMap()
+	err = goconfig.Include(config, m)
+	if err != nil {
+		return
+	}
+	doc := tree
+	renamed := goconfig.RenameAliases(config, m, goconfig.FileKeyOf(keyOf))
+	if goconfig.RenameFileKeys(config, m, keyOf) || renamed {
+		doc, err = toml.TreeFromMap(m)
+		if err != nil {
+			return
+		}
+	}
+	if !goconfig.StrictFile {
+		err = doc.Unmarshal(config)
+		return
+	}
+	if doc != tree {
+		byt, err = doc.Marshal()
+		if err != nil {
+			return
+		}
+	}
+	err = toml.NewDecoder(bytes.NewReader(byt)).Strict(true).Decode(config)
+	err = undecodedKeys(err, tree)
+	return
+}
+
+const undecodedKeysError = "undecoded keys: ["
+
+// undecodedKeys returns err, the error of the strict decoder, as
+// goconfig.ErrUnknownKey listing the keys with their lines in tree, the
+// include key is not reported
+func undecodedKeys(err error, tree *toml.Tree) error {
+	if err == nil || !strings.HasPrefix(err.Error(), undecodedKeysError) {
+		return err
+	}
+	var keys []string
+	lines := make(map[string]int)
+	list := strings.TrimSuffix(strings.TrimPrefix(err.Error(), undecodedKeysError), "]")
+	for list != "" {
+		quoted, quotedErr := strconv.QuotedPrefix(list)
+		if quotedErr != nil {
+			return err
+		}
+		list = strings.TrimPrefix(list[len(quoted):], " ")
+		name, _ := strconv.Unquote(quoted)
+		if goconfig.IsIncludeKey(name) {
+			continue
+		}
+		key, line := locate(tree, strings.Split(name, "."))
+		keys = append(keys, key)
+		lines[key] = line
+	}
+	return goconfig.UnknownKeysError(keys, func(key string) int {
+		return lines[key]
+	})
+}
+
+// locate returns the key path, like servers[1].host, and the line in tree
+// of the path of the strict decoder, where the arrays of tables are
+// followed by the index of their item
+func locate(tree *toml.Tree, path []string) (key string, line int) {
+	for i := 0; i < len(path); i++ {
+		if key != "" {
+			key += "."
+		}
+		key += path[i]
+		if tree == nil {
+			continue
+		}
+		switch node := tree.GetPath(path[i : i+1]).(type) {
+		case *toml.Tree:
+			tree = positioned(node)
+			line = node.Position().Line
+		case []*toml.Tree:
+			index := -1
+			if i+1 < len(path) {
+				if n, err := strconv.Atoi(path[i+1]); err == nil && n < len(node) {
+					index = n
+				}
+			}
+			if index < 0 {
+				line = tree.GetPositionPath(path[i : i+1]).Line
+				tree = nil
+				continue
+			}
+			i++
+			key += fmt.Sprintf("[%d]", index)
+			tree = positioned(node[index])
+			line = node[index].Position().Line
+		default:
+			line = tree.GetPositionPath(path[i : i+1]).Line
+			tree = nil
+		}
+	}
+	return
+}
+
+// positioned returns tree, or nil for the inline tables that have no
+// position and no reliable positions for their keys
+func positioned(tree *toml.Tree) *toml.Tree {
+	if tree.Position().Invalid() {
+		return nil
+	}
+	return tree
+}
+
+// EncodeTOML writes config as a TOML document to w
+func EncodeTOML(w io.Writer, config interface{}) (err error) {
+	var m map[string]interface{}
+	switch {
+	case goconfig.SaveOmitDefaults:
+		m = goconfig.Values(config, goconfig.FileKeyOf(keyOf))
+	case goconfig.RenamedFileKeys():
+		m = goconfig.Map(config, goconfig.FileKeyOf(keyOf))
+	default:
+		err = toml.NewEncoder(w).Encode(config)
+		return
+	}
+	tree, err := toml.TreeFromMap(m)
 	if err != nil {
 		return
 	}
-	err = tree.Unmarshal(config)
+	_, err = tree.WriteTo(w)
 	return
 }
 
+func keyOf(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("toml"), ",")[0]
+	if key == "" {
+		key = field.Name
+	}
+	return key
+}
+
 // PrepareHelp return help string for this file format.
 func PrepareHelp(config interface{}) (help string, err error) {
+	if goconfig.RenamedFileKeys() {
+		var tree *toml.Tree
+		tree, err = toml.TreeFromMap(goconfig.Map(config, goconfig.FileKeyOf(keyOf)))
+		if err != nil {
+			return
+		}
+		help, err = tree.ToTomlString()
+		return
+	}
 	var byt []byte
 	cfg := reflect.ValueOf(config).Elem()
 	byt, err = toml.Marshal(cfg)
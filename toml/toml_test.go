@@ -0,0 +1,66 @@
+package toml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/h2oai/goconfig"
+)
+
+type server struct {
+	Host string `toml:"host"`
+}
+
+type strictConfig struct {
+	Name    string   `toml:"name" cfgAlias:"title"`
+	Servers []server `toml:"servers"`
+	MongoDB server   `toml:"mongodb"`
+	TLS     server   `toml:"tls"`
+}
+
+func TestStrict(t *testing.T) {
+	goconfig.StrictFile = true
+	defer func() {
+		goconfig.StrictFile = false
+	}()
+
+	doc := `# hsot = "commented"
+name = "hsot = in a string"
+include = []
+mongodb = { hsot = "a" }
+
+[tls]
+hsot = "c"
+
+[[servers]]
+host = "a"
+
+[[servers]]
+hsot = "b"
+`
+	err := DecodeTOML(strings.NewReader(doc), &strictConfig{})
+	if !errors.Is(err, goconfig.ErrUnknownKey) {
+		t.Fatal("expected ErrUnknownKey but got", err)
+	}
+	// the keys of the inline tables have no line
+	expected := "unknown config file key: mongodb.hsot, servers[1].hsot (line 13), tls.hsot (line 7)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q but got %q", expected, err.Error())
+	}
+
+	c := &strictConfig{}
+	err = DecodeTOML(strings.NewReader("title = \"x\"\ninclude = \"other.toml\"\n[mongodb]\nhost = \"a\"\n"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "x" || c.MongoDB.Host != "a" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	goconfig.StrictFile = false
+	err = DecodeTOML(strings.NewReader(doc), &strictConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
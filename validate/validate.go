@@ -6,12 +6,19 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/h2oai/goconfig/naming"
 	"github.com/h2oai/goconfig/structtag"
 )
 
 // Prefix is a string that would be placed at the beginning of the generated tags.
 var Prefix string
 
+// Separator is placed between the names of nested fields in the errors, like goflags.Separator
+var Separator = "_"
+
+// Naming maps the field names and the cfg tags to the flag names shown in the errors, the names are lowercased when nil
+var Naming naming.Mapper
+
 // Usage is the function that is called when an error occurs.
 var Usage func()
 
@@ -19,6 +26,10 @@ var Usage func()
 func Setup(tag string, tagDefault string) {
 	structtag.Setup()
 	structtag.Prefix = Prefix
+	if Separator != "" {
+		structtag.TagSeparator = Separator
+	}
+	structtag.Naming = Naming
 	SetTag(tag)
 	SetTagDefault(tagDefault)
 
@@ -69,11 +80,31 @@ func getValue(value *reflect.Value, datatype string) (ret string) {
 	return
 }
 
+// checkOneOf returns an error when the field restricts its values with
+// structtag.TagOneOf and valueStr is not one of them
+func checkOneOf(field *reflect.StructField, valueStr string, tag string) (err error) {
+	oneOf := field.Tag.Get(structtag.TagOneOf)
+	if oneOf == "" {
+		return
+	}
+	for _, v := range strings.Split(oneOf, ",") {
+		if strings.TrimSpace(v) == valueStr {
+			return
+		}
+	}
+	err = fmt.Errorf("-%v must be one of %v", flagName(tag), oneOf)
+	return
+}
+
 func reflectInt(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
 	req := field.Tag.Get("cfgRequired")
 	valueStr := getValue(value, "int")
 	if req == "true" && valueStr == "0" {
-		err = fmt.Errorf("-%v is required", tag)
+		err = fmt.Errorf("-%v is required", flagName(tag))
+		return
+	}
+	if valueStr != "0" {
+		err = checkOneOf(field, valueStr, tag)
 	}
 	return
 }
@@ -82,7 +113,11 @@ func reflectFloat(field *reflect.StructField, value *reflect.Value, tag string)
 	req := field.Tag.Get("cfgRequired")
 	valueStr := getValue(value, "float64")
 	if req == "true" && valueStr == "0" {
-		err = fmt.Errorf("-%v is required", tag)
+		err = fmt.Errorf("-%v is required", flagName(tag))
+		return
+	}
+	if valueStr != "0" {
+		err = checkOneOf(field, valueStr, tag)
 	}
 	return
 }
@@ -91,7 +126,11 @@ func reflectString(field *reflect.StructField, value *reflect.Value, tag string)
 	req := field.Tag.Get("cfgRequired")
 	valueStr := getValue(value, "string")
 	if req == "true" && valueStr == "" {
-		err = fmt.Errorf("-%v is required", strings.ToLower(tag))
+		err = fmt.Errorf("-%v is required", flagName(tag))
+		return
+	}
+	if valueStr != "" {
+		err = checkOneOf(field, valueStr, tag)
 	}
 	return
 }
@@ -99,3 +138,11 @@ func reflectString(field *reflect.StructField, value *reflect.Value, tag string)
 func reflectBool(field *reflect.StructField, value *reflect.Value, tag string) (err error) {
 	return
 }
+
+// flagName returns the flag name of tag shown in the errors
+func flagName(tag string) string {
+	if Naming != nil {
+		return tag
+	}
+	return strings.ToLower(tag)
+}
@@ -48,3 +48,32 @@ func TestParse(t *testing.T) {
 	}
 
 }
+
+func TestOneOf(t *testing.T) {
+	type oneOf struct {
+		Format string `cfg:"format" cfgOneOf:"json,text"`
+		Level  int    `cfg:"level" cfgOneOf:"1,2,3"`
+	}
+
+	Setup("cfg", "cfgDefault")
+
+	err := Parse(&oneOf{Format: "json", Level: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Parse(&oneOf{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Parse(&oneOf{Format: "xml"})
+	if err == nil {
+		t.Fatal("expected error but got nil")
+	}
+
+	err = Parse(&oneOf{Level: 4})
+	if err == nil {
+		t.Fatal("expected error but got nil")
+	}
+}
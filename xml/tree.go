@@ -0,0 +1,132 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// node is an element of the document with the line of its start tag
+type node struct {
+	name     string
+	attrs    []xml.Attr
+	children []*node
+	text     string
+	line     int
+}
+
+// parse reads the document byt as a tree of nodes, returning its root element
+func parse(byt []byte) (root *node, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(byt))
+	var stack []*node
+	for {
+		// the offset of the '<' of a start tag, spaces are read as CharData
+		offset := decoder.InputOffset()
+		var token xml.Token
+		token, err = decoder.Token()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{
+				name:  t.Name.Local,
+				attrs: t.Attr,
+				line:  bytes.Count(byt[:offset], []byte("\n")) + 1,
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		root = &node{}
+	}
+	return
+}
+
+// elements returns the children named name, ignoring case
+func (n *node) elements(name string) (ret []*node) {
+	for _, child := range n.children {
+		if strings.EqualFold(child.name, name) {
+			ret = append(ret, child)
+		}
+	}
+	return
+}
+
+// attr returns the value of the attribute named name, ignoring case
+func (n *node) attr(name string) (value string, ok bool) {
+	for _, a := range n.attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value, true
+		}
+	}
+	return
+}
+
+// value returns the text of a node without children
+func (n *node) value() string {
+	return strings.TrimSpace(n.text)
+}
+
+// toMap returns the attributes and the children of n as nested maps, the
+// repeated elements as lists, used to check the keys and find the includes
+func (n *node) toMap() map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, a := range n.attrs {
+		m[a.Name.Local] = a.Value
+	}
+	for _, child := range n.children {
+		var v interface{} = child.value()
+		if len(child.children) > 0 || len(child.attrs) > 0 {
+			v = child.toMap()
+		}
+		switch prev := m[child.name].(type) {
+		case nil:
+			m[child.name] = v
+		case []interface{}:
+			m[child.name] = append(prev, v)
+		default:
+			m[child.name] = []interface{}{prev, v}
+		}
+	}
+	return m
+}
+
+// lineOf returns the line of the nested key, like servers[1].host, or 0
+func (n *node) lineOf(key string) (line int) {
+	for _, part := range strings.Split(key, ".") {
+		index := 0
+		if i := strings.Index(part, "["); i >= 0 && strings.HasSuffix(part, "]") {
+			index, _ = strconv.Atoi(part[i+1 : len(part)-1])
+			part = part[:i]
+		}
+		elements := n.elements(part)
+		if index >= len(elements) {
+			if _, ok := n.attr(part); ok {
+				line = n.line
+			}
+			return
+		}
+		n = elements[index]
+		line = n.line
+	}
+	return
+}
@@ -0,0 +1,391 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/helper"
+	"github.com/h2oai/goconfig/structtag"
+)
+
+// RootElement is the name of the root element written by EncodeXML and
+// PrepareHelp, any name is accepted when loading
+var RootElement = "config"
+
+func init() {
+	err := goconfig.RegisterFormat(goconfig.Fileformat{
+		Name:        "xml",
+		Extension:   ".xml",
+		MIMETypes:   []string{"application/xml", "text/xml"},
+		Comments:    true,
+		Load:        LoadXML,
+		Decode:      DecodeXML,
+		Encode:      EncodeXML,
+		KeyOf:       keyOf,
+		PrepareHelp: PrepareHelp,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// LoadXML config file
+func LoadXML(config interface{}) (err error) {
+	configFile := filepath.Join(goconfig.Path, goconfig.File)
+	file, err := os.Open(configFile)
+	if err != nil {
+		if os.IsNotExist(err) && !goconfig.FileRequired {
+			err = nil
+		}
+		return
+	}
+	defer helper.Closer(file)
+
+	err = DecodeXML(file, config)
+	return
+}
+
+// DecodeXML reads the XML document from r into config. The child elements
+// and the attributes of the root element are named after the xml tag of
+// the fields, or their cfg tag, and repeated elements fill the lists.
+func DecodeXML(r io.Reader, config interface{}) (err error) {
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	root, err := parse(byt)
+	if err != nil {
+		return
+	}
+
+	m := root.toMap()
+	if goconfig.StrictFile {
+		err = goconfig.UnknownKeysError(goconfig.UnknownKeys(config, m, keyOf), root.lineOf)
+		if err != nil {
+			return
+		}
+	}
+	err = goconfig.Include(config, m)
+	if err != nil {
+		return
+	}
+
+	value := reflect.ValueOf(config)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		err = structtag.ErrNotAStruct
+		return
+	}
+	err = decode(value, root, "")
+	return
+}
+
+// decode sets the fields of the struct value from the node n
+func decode(value reflect.Value, n *node, path string) (err error) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldValue := value.Field(i)
+		key, attr := fieldKey(field)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && key == field.Name {
+			err = decode(fieldValue, n, path)
+			if err != nil {
+				return
+			}
+			continue
+		}
+		if field.PkgPath != "" || key == "" || key == "-" || structtag.IsCommand(&field) {
+			continue
+		}
+
+		name, elements, text, ok := lookup(n, &field, key, attr)
+		if !ok {
+			continue
+		}
+		if structtag.IsDeprecated(&field) && name == key {
+			goconfig.Warn(structtag.Deprecation(&field, fmt.Sprintf("config file key %q", path+key), ""))
+		}
+
+		switch fieldValue.Kind() {
+		case reflect.Struct:
+			err = decode(fieldValue, elements[0], path+key+".")
+		case reflect.Slice:
+			list := reflect.MakeSlice(fieldValue.Type(), len(elements), len(elements))
+			for j, element := range elements {
+				item := fmt.Sprintf("%v%v[%d]", path, key, j)
+				if list.Index(j).Kind() == reflect.Struct {
+					err = decode(list.Index(j), element, item+".")
+				} else {
+					err = setValue(list.Index(j), element.value(), item, element.line)
+				}
+				if err != nil {
+					return
+				}
+			}
+			fieldValue.Set(list)
+		default:
+			line := n.line
+			if len(elements) > 0 {
+				line = elements[0].line
+			}
+			err = setValue(fieldValue, text, path+key, line)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// lookup returns the elements or the attribute of n setting field, by key
+// or one of the cfgAlias names
+func lookup(n *node, field *reflect.StructField, key string, attr bool) (name string, elements []*node, text string, ok bool) {
+	names := []string{key}
+	for _, alias := range strings.Split(field.Tag.Get(structtag.TagAlias), ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			names = append(names, alias)
+		}
+	}
+	for _, name = range names {
+		if !attr {
+			elements = n.elements(name)
+			if len(elements) > 0 {
+				text, ok = elements[0].value(), true
+			}
+		}
+		if !ok && field.Type.Kind() != reflect.Struct && field.Type.Kind() != reflect.Slice {
+			text, ok = n.attr(name)
+		}
+		if ok {
+			if name != key {
+				goconfig.Warn(structtag.Deprecation(field,
+					fmt.Sprintf("config file key %q", name),
+					fmt.Sprintf("%q", key)))
+			}
+			return
+		}
+	}
+	return
+}
+
+// setValue parses s into the scalar value
+func setValue(value reflect.Value, s, key string, line int) (err error) {
+	if value.Kind() == reflect.Slice {
+		err = fmt.Errorf("%w: %v", structtag.ErrTypeNotSupported, value.Type())
+	} else {
+		err = helper.ParseValue(value, s)
+	}
+	if err != nil {
+		err = fmt.Errorf("%v (line %d): %w", key, line, err)
+	}
+	return
+}
+
+// fieldKey returns the name of the element or attribute of field, from its
+// xml tag, its cfg tag or its name changed by goconfig.FileNaming, the cfg
+// tag comes first with goconfig.CfgFileKeys
+func fieldKey(field reflect.StructField) (key string, attr bool) {
+	parts := strings.Split(field.Tag.Get("xml"), ",")
+	key = parts[0]
+	for _, option := range parts[1:] {
+		attr = attr || option == "attr"
+	}
+	if key != "" && !(goconfig.CfgFileKeys && field.Tag.Get(goconfig.Tag) != "") {
+		return
+	}
+	key = field.Tag.Get(goconfig.Tag)
+	if key == "" {
+		key = field.Name
+	}
+	if !field.Anonymous {
+		key = goconfig.FileKey(key)
+	}
+	return
+}
+
+func keyOf(field reflect.StructField) string {
+	key, _ := fieldKey(field)
+	return key
+}
+
+// EncodeXML writes config as an indented XML document to w
+func EncodeXML(w io.Writer, config interface{}) (err error) {
+	err = encode(w, config, false)
+	return
+}
+
+// PrepareHelp return help string for this file format.
+func PrepareHelp(config interface{}) (help string, err error) {
+	var buf bytes.Buffer
+	err = encode(&buf, config, true)
+	help = buf.String()
+	return
+}
+
+// encode writes config under RootElement, the help adds comments with
+// cfgHelper and cfgDefault and an element for the empty lists
+func encode(w io.Writer, config interface{}, help bool) (err error) {
+	value := reflect.ValueOf(config)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		err = structtag.ErrNotAStruct
+		return
+	}
+	e := &encoder{Encoder: xml.NewEncoder(w), help: help}
+	e.Indent("", indent)
+	err = e.element(xml.StartElement{Name: xml.Name{Local: RootElement}}, value)
+	if err != nil {
+		return
+	}
+	err = e.Flush()
+	if err != nil {
+		return
+	}
+	_, err = io.WriteString(w, "\n")
+	return
+}
+
+const indent = "    "
+
+type encoder struct {
+	*xml.Encoder
+	help  bool
+	depth int
+}
+
+// element writes the struct value as the element start
+func (e *encoder) element(start xml.StartElement, value reflect.Value) (err error) {
+	e.attrs(&start, value)
+	err = e.EncodeToken(start)
+	if err != nil {
+		return
+	}
+	e.depth++
+	err = e.fields(value)
+	e.depth--
+	if err != nil {
+		return
+	}
+	err = e.EncodeToken(start.End())
+	return
+}
+
+// attrs adds the fields of value written as attributes to start
+func (e *encoder) attrs(start *xml.StartElement, value reflect.Value) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, attr := fieldKey(field)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && key == field.Name {
+			e.attrs(start, value.Field(i))
+			continue
+		}
+		if !attr || field.PkgPath != "" || key == "-" || e.omit(field, value.Field(i)) {
+			continue
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: key}, Value: helper.FormatValue(value.Field(i))})
+	}
+}
+
+// fields writes the fields of value written as elements
+func (e *encoder) fields(value reflect.Value) (err error) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldValue := value.Field(i)
+		key, attr := fieldKey(field)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && key == field.Name {
+			err = e.fields(fieldValue)
+			if err != nil {
+				return
+			}
+			continue
+		}
+		if attr || field.PkgPath != "" || key == "-" || structtag.IsCommand(&field) || e.omit(field, fieldValue) {
+			continue
+		}
+		err = e.comment(field)
+		if err != nil {
+			return
+		}
+
+		start := xml.StartElement{Name: xml.Name{Local: key}}
+		switch fieldValue.Kind() {
+		case reflect.Struct:
+			err = e.element(start, fieldValue)
+		case reflect.Slice, reflect.Array:
+			list := fieldValue
+			if list.Len() == 0 && e.help {
+				list = reflect.MakeSlice(reflect.SliceOf(fieldValue.Type().Elem()), 1, 1)
+			}
+			for j := 0; j < list.Len() && err == nil; j++ {
+				if list.Index(j).Kind() == reflect.Struct {
+					err = e.element(start, list.Index(j))
+				} else {
+					err = e.EncodeElement(helper.FormatValue(list.Index(j)), start)
+				}
+			}
+		default:
+			err = e.EncodeElement(helper.FormatValue(fieldValue), start)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// omit returns true for the deprecated fields and, when saving with
+// SaveOmitDefaults, the values equal to their default
+func (e *encoder) omit(field reflect.StructField, value reflect.Value) bool {
+	if structtag.IsDeprecated(&field) {
+		return true
+	}
+	if e.help || !goconfig.SaveOmitDefaults {
+		return false
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		return false
+	case reflect.Slice, reflect.Array:
+		return value.Len() == 0
+	}
+	return structtag.IsDefault(value, field.Tag.Get(goconfig.TagDefault))
+}
+
+// comment writes the cfgHelper and cfgDefault of field in the help
+func (e *encoder) comment(field reflect.StructField) (err error) {
+	if !e.help {
+		return
+	}
+	var parts []string
+	if help := field.Tag.Get(goconfig.TagHelper); help != "" {
+		parts = append(parts, help)
+	}
+	if def := field.Tag.Get(goconfig.TagDefault); def != "" {
+		parts = append(parts, fmt.Sprintf("(default %q)", def))
+	}
+	if len(parts) == 0 {
+		return
+	}
+	text := strings.Replace(strings.Join(parts, " "), "--", "- -", -1)
+	// the encoder does not indent the comments
+	err = e.EncodeToken(xml.CharData("\n" + strings.Repeat(indent, e.depth)))
+	if err != nil {
+		return
+	}
+	err = e.EncodeToken(xml.Comment(" " + text + " "))
+	return
+}
@@ -0,0 +1,128 @@
+package xml
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/h2oai/goconfig"
+)
+
+type server struct {
+	Name string `xml:"name,attr"`
+	Host string `xml:"host"`
+	Port int    `xml:"port"`
+}
+
+type testConfig struct {
+	Version int      `xml:"version,attr"`
+	Name    string   `cfg:"name"`
+	Debug   bool     `cfg:"debug"`
+	Hosts   []string `xml:"host"`
+	MongoDB server   `xml:"mongodb"`
+	Servers []server `xml:"server"`
+}
+
+const doc = `<?xml version="1.0"?>
+<!-- the config -->
+<config version="2">
+	<name>
+		my app
+	</name>
+  <debug>true</debug>
+	<host>a</host>
+	<host> b </host>
+	<mongodb name="main">
+		<host>db</host>
+		<port>27017</port>
+	</mongodb>
+	<server
+		name="one">
+		<host>one.example.com</host>
+	</server>
+	<server name="two"><port>2</port></server>
+</config>
+`
+
+func TestDecodeXML(t *testing.T) {
+	c := &testConfig{}
+	err := DecodeXML(strings.NewReader(doc), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &testConfig{
+		Version: 2,
+		Name:    "my app",
+		Debug:   true,
+		Hosts:   []string{"a", "b"},
+		MongoDB: server{Name: "main", Host: "db", Port: 27017},
+		Servers: []server{{Name: "one", Host: "one.example.com"}, {Name: "two", Port: 2}},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, c)
+	}
+
+	err = DecodeXML(strings.NewReader("<config>\n<server>\n<port>many</port>\n</server>\n</config>"), &testConfig{})
+	if err == nil || !strings.Contains(err.Error(), "server[0].port (line 3)") {
+		t.Fatal("expected an error naming the key and its line but got", err)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	goconfig.StrictFile = true
+	defer func() {
+		goconfig.StrictFile = false
+	}()
+
+	doc := `<config nmae="x">
+	<!-- <hsot>commented</hsot> -->
+	<name>hsot</name>
+	<mongodb>
+		<hsot>db</hsot>
+	</mongodb>
+	<server name="one"/>
+	<server
+		nmae="two">
+	</server>
+</config>
+`
+	err := DecodeXML(strings.NewReader(doc), &testConfig{})
+	if !errors.Is(err, goconfig.ErrUnknownKey) {
+		t.Fatal("expected ErrUnknownKey but got", err)
+	}
+	expected := "unknown config file key: mongodb.hsot (line 5), nmae (line 1), server[1].nmae (line 8)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q but got %q", expected, err.Error())
+	}
+}
+
+func TestEncodeXML(t *testing.T) {
+	c := &testConfig{
+		Version: 1,
+		Name:    "a & b",
+		Hosts:   []string{"a", "b"},
+		MongoDB: server{Name: "main", Host: "db"},
+		Servers: []server{{Name: "one"}, {Name: "two", Port: 2}},
+	}
+	var buf bytes.Buffer
+	err := EncodeXML(&buf, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`<config version="1">`, "    <name>a &amp; b</name>", "    <host>b</host>", `    <mongodb name="main">`} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("expected %v in\n%v", line, buf.String())
+		}
+	}
+
+	decoded := &testConfig{}
+	err = DecodeXML(&buf, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, decoded) {
+		t.Fatalf("expected %+v, got %+v", c, decoded)
+	}
+}
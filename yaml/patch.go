@@ -0,0 +1,266 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/h2oai/goconfig"
+	"gopkg.in/yaml.v2"
+)
+
+// Patch changes the values of the block style YAML document doc line by
+// line, keeping the comments and the order of the keys. Missing keys are
+// added at the end of their parent mapping. It returns goconfig.ErrPatch
+// when a parent of a key is not a block mapping, like a flow mapping.
+func Patch(doc []byte, edits []goconfig.Edit) (ret []byte, err error) {
+	eol := "\n"
+	if bytes.Contains(doc, []byte("\r\n")) {
+		eol = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(string(doc), "\r\n", "\n"), "\n")
+	for _, edit := range edits {
+		var value string
+		value, err = formatValue(edit.Value)
+		if err != nil {
+			return
+		}
+		lines, err = patchLines(lines, edit.Keys, value)
+		if err != nil {
+			return
+		}
+	}
+	ret = []byte(strings.Join(lines, eol))
+	return
+}
+
+// patchLines sets the value of the nested keys
+func patchLines(lines []string, keys []string, value string) (ret []string, err error) {
+	start, end, parent := 0, len(lines), -1
+	for depth, key := range keys {
+		if !blockMapping(lines, start, end) {
+			err = notBlockMapping(keys, depth)
+			return
+		}
+		indent := childIndent(lines, start, end, parent)
+		i := findKey(lines, start, end, indent, key)
+		if i < 0 {
+			ret = insertKeys(lines, start, end, indent, keys[depth:], value)
+			return
+		}
+		if depth == len(keys)-1 {
+			ret = replaceValue(lines, i, indent, value)
+			return
+		}
+		_, rest, _ := splitKey(strings.TrimSpace(lines[i]))
+		if !blockValue(rest) {
+			err = notBlockMapping(keys, depth+1)
+			return
+		}
+		parent = indent
+		start, end = i+1, blockEnd(lines, i+1, indent)
+	}
+	ret = lines
+	return
+}
+
+// notBlockMapping returns the error for the parent of keys[depth]
+func notBlockMapping(keys []string, depth int) error {
+	parent := "the document"
+	if depth > 0 {
+		parent = strings.Join(keys[:depth], ".")
+	}
+	return fmt.Errorf("%w %v: %v is not a block mapping", goconfig.ErrPatch, strings.Join(keys, "."), parent)
+}
+
+// blockMapping returns false when the first line between start and end is
+// a flow collection or a sequence item instead of a key
+func blockMapping(lines []string, start, end int) bool {
+	for i := start; i < end; i++ {
+		if isContent(lines[i]) {
+			trimmed := strings.TrimSpace(lines[i])
+			return !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") &&
+				!strings.HasPrefix(trimmed, "- ") && trimmed != "-"
+		}
+	}
+	return true
+}
+
+// blockValue returns true when the value after a key, without its comment,
+// is empty or only has an anchor or a tag, so the value is on the next lines
+func blockValue(rest string) bool {
+	if c := commentIndex(rest); c >= 0 {
+		rest = rest[:c]
+	}
+	for _, field := range strings.Fields(rest) {
+		if !strings.HasPrefix(field, "&") && !strings.HasPrefix(field, "!") {
+			return false
+		}
+	}
+	return true
+}
+
+// childIndent returns the indentation of the keys between start and end,
+// or the indentation for a new key when there are none
+func childIndent(lines []string, start, end, parent int) int {
+	for i := start; i < end; i++ {
+		if isContent(lines[i]) {
+			return indentOf(lines[i])
+		}
+	}
+	if parent < 0 {
+		return 0
+	}
+	return parent + 2
+}
+
+// findKey returns the index of the line defining key at indent, or -1
+func findKey(lines []string, start, end, indent int, key string) int {
+	for i := start; i < end; i++ {
+		line := lines[i]
+		if !isContent(line) || indentOf(line) != indent {
+			continue
+		}
+		k, _, ok := splitKey(strings.TrimSpace(line))
+		if ok && strings.EqualFold(k, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// blockEnd returns the index of the first line after start that is not
+// part of the block indented deeper than indent
+func blockEnd(lines []string, start, indent int) int {
+	end := start
+	for i := start; i < len(lines); i++ {
+		if !isContent(lines[i]) {
+			continue
+		}
+		n := indentOf(lines[i])
+		if n < indent || n == indent && !strings.HasPrefix(strings.TrimSpace(lines[i]), "- ") {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// replaceValue sets the value of the key defined on line i, removing the
+// nested lines of its previous value and keeping its comment
+func replaceValue(lines []string, i, indent int, value string) []string {
+	line := lines[i]
+	trimmed := strings.TrimSpace(line)
+	_, rest, _ := splitKey(trimmed)
+	prefix := line[:indentOf(line)] + trimmed[:len(trimmed)-len(rest)]
+	comment := ""
+	if c := commentIndex(rest); c >= 0 {
+		comment = rest[len(strings.TrimRight(rest[:c], " \t")):]
+		if !strings.HasPrefix(comment, " ") && !strings.HasPrefix(comment, "\t") {
+			comment = " " + comment
+		}
+	}
+	lines[i] = prefix + " " + value + comment
+
+	return append(lines[:i+1], lines[blockEnd(lines, i+1, indent):]...)
+}
+
+// insertKeys adds the nested keys with value after the last line of the block
+func insertKeys(lines []string, start, end, indent int, keys []string, value string) []string {
+	at := start
+	for i := start; i < end; i++ {
+		if isContent(lines[i]) {
+			at = i + 1
+		}
+	}
+	var added []string
+	for j, key := range keys {
+		line := strings.Repeat(" ", indent+2*j) + key + ":"
+		if j == len(keys)-1 {
+			line += " " + value
+		}
+		added = append(added, line)
+	}
+	if at == len(lines) && at > 0 && lines[at-1] == "" {
+		// keep the final newline
+		at--
+	}
+	ret := append([]string{}, lines[:at]...)
+	ret = append(ret, added...)
+	return append(ret, lines[at:]...)
+}
+
+// splitKey splits a "key: value" line, ok is false when it does not define a key
+func splitKey(line string) (key, rest string, ok bool) {
+	if strings.HasPrefix(line, `"`) || strings.HasPrefix(line, `'`) {
+		q := line[:1]
+		end := strings.Index(line[1:], q)
+		if end < 0 || !strings.HasPrefix(line[end+2:], ":") {
+			return
+		}
+		key, rest, ok = line[1:end+1], line[end+3:], true
+		return
+	}
+	for i := 0; i < len(line); i++ {
+		if line[i] == ':' && (i == len(line)-1 || line[i+1] == ' ' || line[i+1] == '\t') {
+			key, rest, ok = line[:i], line[i+1:], true
+			return
+		}
+	}
+	return
+}
+
+// commentIndex returns the index of the comment of a value, or -1
+func commentIndex(value string) int {
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t'):
+			return i
+		}
+	}
+	return -1
+}
+
+func isContent(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#") && trimmed != "---"
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// formatValue returns the single line YAML representation of v
+func formatValue(v interface{}) (ret string, err error) {
+	if d, ok := v.(time.Duration); ok {
+		ret = d.String()
+		return
+	}
+	var byt []byte
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		byt, err = json.Marshal(v)
+	default:
+		byt, err = yaml.Marshal(v)
+		if err == nil && strings.Count(strings.TrimSuffix(string(byt), "\n"), "\n") > 0 {
+			byt, err = json.Marshal(v)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %v", goconfig.ErrPatch, err)
+		return
+	}
+	ret = strings.TrimSuffix(string(byt), "\n")
+	return
+}
@@ -0,0 +1,72 @@
+package yaml
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/h2oai/goconfig"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		doc      string
+		edits    []goconfig.Edit
+		expected string
+	}{
+		{
+			"# app\nname: old # the name\nmongodb:\n  host: localhost\n  port: 1\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "port"}, Value: 27017}, {Keys: []string{"name"}, Value: "app"}},
+			"# app\nname: app # the name\nmongodb:\n  host: localhost\n  port: 27017\n",
+		},
+		{
+			"name: app\nmongodb:\n    host: localhost\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "port"}, Value: 1}, {Keys: []string{"timeout"}, Value: time.Second}},
+			"name: app\nmongodb:\n    host: localhost\n    port: 1\ntimeout: 1s\n",
+		},
+		{
+			"name: app\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "tls", "cert"}, Value: "a.pem"}},
+			"name: app\nmongodb:\n  tls:\n    cert: a.pem\n",
+		},
+		{
+			"hosts:\n  - a\n  - b\nname: app\n",
+			[]goconfig.Edit{{Keys: []string{"hosts"}, Value: []string{"c"}}},
+			"hosts: [\"c\"]\nname: app\n",
+		},
+		{
+			"\"name\": old\r\nmongodb: &m\r\n  host: localhost # the host\r\n",
+			[]goconfig.Edit{{Keys: []string{"mongodb", "host"}, Value: "db"}, {Keys: []string{"mongodb", "port"}, Value: 1}},
+			"\"name\": old\r\nmongodb: &m\r\n  host: db # the host\r\n  port: 1\r\n",
+		},
+	}
+	for _, test := range tests {
+		byt, err := Patch([]byte(test.doc), test.edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(byt) != test.expected {
+			t.Errorf("expected %q but got %q", test.expected, string(byt))
+		}
+		var m map[string]interface{}
+		if err = yaml.Unmarshal(byt, &m); err != nil {
+			t.Errorf("invalid document %q: %v", string(byt), err)
+		}
+	}
+}
+
+func TestPatchNotBlock(t *testing.T) {
+	for _, doc := range []string{
+		"mongodb: {host: localhost}\n",
+		"mongodb: [a]\n",
+		"mongodb: localhost\n",
+		"mongodb:\n  - host: localhost\n",
+		"{mongodb: {host: localhost}}\n",
+	} {
+		_, err := Patch([]byte(doc), []goconfig.Edit{{Keys: []string{"mongodb", "host"}, Value: "db"}})
+		if !errors.Is(err, goconfig.ErrPatch) {
+			t.Errorf("expected ErrPatch for %q but got %v", doc, err)
+		}
+	}
+}
@@ -1,49 +1,233 @@
 package yaml
 
 import (
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/helper"
 	"gopkg.in/yaml.v2"
 )
 
 func init() {
-	f := goconfig.Fileformat{
+	err := goconfig.RegisterFormat(goconfig.Fileformat{
+		Name:        "yaml",
 		Extension:   ".yaml",
+		Extensions:  []string{".yml"},
+		MIMETypes:   []string{"application/yaml", "application/x-yaml", "text/yaml"},
+		Comments:    true,
 		Load:        LoadYAML,
+		Decode:      DecodeYAML,
+		Encode:      EncodeYAML,
+		KeyOf:       keyOf,
+		Patch:       Patch,
 		PrepareHelp: PrepareHelp,
+	})
+	if err != nil {
+		panic(err)
 	}
-	goconfig.Formats = append(goconfig.Formats, f)
-	f.Extension = ".yml"
-	goconfig.Formats = append(goconfig.Formats, f)
 }
 
 // LoadYAML config file
 func LoadYAML(config interface{}) (err error) {
 	configFile := filepath.Join(goconfig.Path, goconfig.File)
-	file, err := ioutil.ReadFile(configFile)
+	file, err := os.Open(configFile)
 	if os.IsNotExist(err) && !goconfig.FileRequired {
 		err = nil
 		return
 	} else if err != nil {
 		return
 	}
+	defer helper.Closer(file)
 
-	err = yaml.Unmarshal(file, config)
+	err = DecodeYAML(file, config)
+	return
+}
 
+// DecodeYAML reads the YAML document from r into config
+func DecodeYAML(r io.Reader, config interface{}) (err error) {
+	byt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	doc, err := preprocess(byt, config)
 	if err != nil {
 		return
 	}
+	if !goconfig.StrictFile {
+		err = yaml.Unmarshal(doc, config)
+		return
+	}
+	err = yaml.UnmarshalStrict(doc, config)
+	err = unknownFields(err, byt, config)
+	return
+}
 
+// preprocess applies the included files and the keys listed on cfgAlias to
+// the document byt
+func preprocess(byt []byte, config interface{}) (ret []byte, err error) {
+	ret = byt
+	var m map[interface{}]interface{}
+	if yaml.Unmarshal(byt, &m) != nil {
+		// let the struct decoding report the error
+		return
+	}
+	err = goconfig.Include(config, m)
+	if err != nil {
+		return
+	}
+	keys := goconfig.FileKeyOf(keyOf)
+	renamed := goconfig.RenameAliases(config, m, keys)
+	if goconfig.RenameFileKeys(config, m, keyOf) || renamed {
+		ret, err = yaml.Marshal(m)
+	}
 	return
 }
 
+var unknownField = regexp.MustCompile(`^line (\d+): field (.+) not found in type (.+)$`)
+
+// unknownFields returns err, the error of UnmarshalStrict into config, as
+// goconfig.ErrUnknownKey when it only reports unknown keys, listing every
+// unknown key of the document byt, read before the aliases were renamed,
+// with its path and its line. The include key is not reported.
+func unknownFields(err error, byt []byte, config interface{}) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	unknown := false
+	for _, msg := range typeErr.Errors {
+		match := unknownField.FindStringSubmatch(msg)
+		if match == nil {
+			return err
+		}
+		if !goconfig.IsIncludeKey(match[2]) || match[3] != reflect.TypeOf(config).Elem().String() {
+			unknown = true
+		}
+	}
+	if !unknown {
+		return nil
+	}
+	var m map[interface{}]interface{}
+	if yaml.Unmarshal(byt, &m) != nil {
+		return err
+	}
+	keys := goconfig.UnknownKeys(config, m, goconfig.FileKeyOf(keyOf))
+	if len(keys) == 0 {
+		return err
+	}
+	lines := strings.Split(strings.ReplaceAll(string(byt), "\r\n", "\n"), "\n")
+	return goconfig.UnknownKeysError(keys, func(key string) int {
+		return keyLine(lines, key)
+	})
+}
+
+var pathIndex = regexp.MustCompile(`\[(\d+)\]`)
+
+// keyLine returns the line of the key at path, like servers[1].host, in the
+// block style YAML document lines, or the line of the flow collection
+// holding it, 0 when it is not found
+func keyLine(lines []string, path string) (line int) {
+	// the sequence items are rewritten as mappings
+	lines = append([]string{}, lines...)
+	start, end, parent := 0, len(lines), -1
+	flow := false
+	for _, segment := range strings.Split(pathIndex.ReplaceAllString(path, ".[$1]"), ".") {
+		if segment == "" {
+			continue
+		}
+		if flow {
+			return
+		}
+		indent := childIndent(lines, start, end, parent)
+		if strings.HasPrefix(segment, "[") {
+			n, _ := strconv.Atoi(strings.Trim(segment, "[]"))
+			i := sequenceItem(lines, start, end, indent, n)
+			if i < 0 {
+				line = 0
+				return
+			}
+			trimmed := strings.TrimSpace(lines[i])
+			rest := strings.TrimLeft(trimmed[1:], " ")
+			lines[i] = strings.Repeat(" ", indent+len(trimmed)-len(rest)) + rest
+			line, parent = i+1, indent
+			start, end = i, blockEnd(lines, i+1, indent+1)
+			continue
+		}
+		if !blockMapping(lines, start, end) {
+			if line == 0 {
+				return
+			}
+			flow = true
+			continue
+		}
+		i := findKey(lines, start, end, indent, segment)
+		if i < 0 {
+			line = 0
+			return
+		}
+		_, rest, _ := splitKey(strings.TrimSpace(lines[i]))
+		line, parent, flow = i+1, indent, !blockValue(rest)
+		start, end = i+1, blockEnd(lines, i+1, indent)
+	}
+	return
+}
+
+// sequenceItem returns the index of the line starting the item n of the
+// block sequence at indent, or -1
+func sequenceItem(lines []string, start, end, indent, n int) int {
+	for i := start; i < end; i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if !isContent(lines[i]) || indentOf(lines[i]) != indent || !strings.HasPrefix(trimmed, "- ") && trimmed != "-" {
+			continue
+		}
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return -1
+}
+
+// EncodeYAML writes config as a YAML document to w
+func EncodeYAML(w io.Writer, config interface{}) (err error) {
+	var v interface{} = config
+	if goconfig.SaveOmitDefaults {
+		v = goconfig.Values(config, goconfig.FileKeyOf(keyOf))
+	} else if goconfig.RenamedFileKeys() {
+		v = goconfig.Map(config, goconfig.FileKeyOf(keyOf))
+	}
+	byt, err := yaml.Marshal(v)
+	if err != nil {
+		return
+	}
+	_, err = w.Write(byt)
+	return
+}
+
+func keyOf(field reflect.StructField) string {
+	key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if key == "" {
+		key = strings.ToLower(field.Name)
+	}
+	return key
+}
+
 // PrepareHelp return help string for this file format.
 func PrepareHelp(config interface{}) (help string, err error) {
+	var v interface{} = &config
+	if goconfig.RenamedFileKeys() {
+		v = goconfig.Map(config, goconfig.FileKeyOf(keyOf))
+	}
 	var helpAux []byte
-	helpAux, err = yaml.Marshal(&config)
+	helpAux, err = yaml.Marshal(v)
 	if err != nil {
 		return
 	}
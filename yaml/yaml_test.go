@@ -0,0 +1,92 @@
+package yaml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/h2oai/goconfig"
+	"github.com/h2oai/goconfig/naming"
+)
+
+type server struct {
+	Host string `yaml:"host"`
+}
+
+type strictConfig struct {
+	Name    string   `yaml:"name" cfgAlias:"title"`
+	Servers []server `yaml:"servers"`
+	MongoDB server   `yaml:"mongodb" cfgAlias:"database"`
+}
+
+func TestStrict(t *testing.T) {
+	goconfig.StrictFile = true
+	defer func() {
+		goconfig.StrictFile = false
+	}()
+
+	doc := `# hsot: commented
+name: "hsot: in a string"
+include: []
+mongodb: {hsot: a}
+servers:
+  - host: a
+  - hsot: b
+`
+	err := DecodeYAML(strings.NewReader(doc), &strictConfig{})
+	if !errors.Is(err, goconfig.ErrUnknownKey) {
+		t.Fatal("expected ErrUnknownKey but got", err)
+	}
+	expected := "unknown config file key: mongodb.hsot (line 4), servers[1].hsot (line 7)"
+	if err.Error() != expected {
+		t.Fatalf("expected %q but got %q", expected, err.Error())
+	}
+
+	// the keys are found in the document before the aliases are renamed
+	doc = `title: x
+nmae: y
+database:
+  # hsot: commented
+  host: a
+  hsot: b
+servers:
+- host: a
+-   hsot: c
+    port: 1
+`
+	err = DecodeYAML(strings.NewReader(doc), &strictConfig{})
+	expected = "unknown config file key: database.hsot (line 6), nmae (line 2), servers[1].hsot (line 9), servers[1].port (line 10)"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q but got %v", expected, err)
+	}
+
+	goconfig.FileNaming = naming.Kebab
+	err = DecodeYAML(strings.NewReader("mongo-db:\n  hsot: a\n"), &struct {
+		MongoDB server
+	}{})
+	goconfig.FileNaming = nil
+	if err == nil || err.Error() != "unknown config file key: mongo-db.hsot (line 2)" {
+		t.Fatal("unexpected error", err)
+	}
+
+	// the type errors are kept
+	err = DecodeYAML(strings.NewReader("servers: a\n"), &strictConfig{})
+	if err == nil || errors.Is(err, goconfig.ErrUnknownKey) {
+		t.Fatal("expected a type error but got", err)
+	}
+
+	c := &strictConfig{}
+	err = DecodeYAML(strings.NewReader("title: x\ninclude: other.yaml\nmongodb: {host: a}\n"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "x" || c.MongoDB.Host != "a" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+
+	goconfig.StrictFile = false
+	err = DecodeYAML(strings.NewReader(doc), &strictConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}